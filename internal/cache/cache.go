@@ -1,45 +1,102 @@
 package cache
 
 import (
+	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"strings"
 
+	"github.com/runs-on/action/internal/config"
+	"github.com/runs-on/action/internal/httpclient"
 	"github.com/sethvargo/go-githubactions"
 )
 
 // UpdateZctionsConfig sends the original GitHub backend URLs and runtime token
-// to the local RunsOn cache service. The token is intentionally never logged.
-func UpdateZctionsConfig(action *githubactions.Action, actionsResultsURL string, zctionsResultsURL string, zctionsCacheURL string, actionsRuntimeToken string) {
-	if zctionsResultsURL == "" {
-		return
+// to the local RunsOn cache service, then checks that the service is healthy.
+// When the service cannot be configured, the following steps are pointed back to the GitHub backends.
+// The token is intentionally never logged.
+func UpdateZctionsConfig(action *githubactions.Action, client *httpclient.Client, cfg *config.Config) error {
+	if cfg.ZctionsResultsURL == "" {
+		return nil
 	}
 
-	configURL := actionsResultsURL + "config"
+	if cfg.MagicCache == config.MagicCacheNone {
+		useGithubBackends(action, cfg)
+		action.Infof("Magic cache is disabled, cache and artifact requests will go to GitHub.")
+		return nil
+	}
+
+	if err := configureService(action, client, cfg); err != nil {
+		useGithubBackends(action, cfg)
+		return err
+	}
+	return nil
+}
+
+// useGithubBackends points the following steps back to the GitHub backends, instead of the local cache service.
+func useGithubBackends(action *githubactions.Action, cfg *config.Config) {
+	action.SetEnv("ACTIONS_RESULTS_URL", cfg.ZctionsResultsURL)
+	if cfg.ZctionsCacheURL != "" {
+		action.SetEnv("ACTIONS_CACHE_URL", cfg.ZctionsCacheURL)
+	}
+}
+
+// configureService sends the config to the local cache service, and checks its health.
+func configureService(action *githubactions.Action, client *httpclient.Client, cfg *config.Config) error {
+	configURL := cfg.ActionsResultsURL + "config"
 	data := url.Values{}
 	// Send the ZCTIONS_RESULTS_URL value under the key 'ACTIONS_RESULTS_URL'.
 	// This value is only known by the GitHub Actions runner, and is needed by the RunsOn agent cache proxy to handle artefacts caching.
-	data.Set("ACTIONS_RESULTS_URL", zctionsResultsURL)
-	if zctionsCacheURL != "" {
-		data.Set("ACTIONS_CACHE_URL", zctionsCacheURL)
+	data.Set("ACTIONS_RESULTS_URL", cfg.ZctionsResultsURL)
+	if cfg.ZctionsCacheURL != "" {
+		data.Set("ACTIONS_CACHE_URL", cfg.ZctionsCacheURL)
 	}
-	if actionsRuntimeToken != "" {
-		data.Set("ACTIONS_RUNTIME_TOKEN", actionsRuntimeToken)
+	// Tell the cache service which traffic to handle: the rest is passed through to GitHub.
+	data.Set("MAGIC_CACHE", cfg.MagicCache)
+	setScopeValues(action, cfg, data)
+	if cfg.ActionsRuntimeToken != "" {
+		data.Set("ACTIONS_RUNTIME_TOKEN", cfg.ActionsRuntimeToken)
 	}
 
 	req, err := http.NewRequest(http.MethodPut, configURL, strings.NewReader(data.Encode()))
 	if err != nil {
-		action.Errorf("Failed to create config update request: %v", err)
-		return
+		return fmt.Errorf("failed to create config update request: %w", err)
 	}
-
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	client := &http.Client{}
+
+	if err := send(client, req); err != nil {
+		return fmt.Errorf("failed to update cache service config: %w", err)
+	}
+	action.Infof("Cache service config updated.")
+
+	if err := CheckHealth(client, cfg.ActionsResultsURL); err != nil {
+		return fmt.Errorf("cache service is not healthy after config update: %w", err)
+	}
+	action.Infof("Cache service is healthy.")
+	return nil
+}
+
+// CheckHealth runs a round trip against the health endpoint of the local RunsOn cache service.
+func CheckHealth(client *httpclient.Client, baseURL string) error {
+	req, err := http.NewRequest(http.MethodGet, baseURL+"health", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create health check request: %w", err)
+	}
+	return send(client, req)
+}
+
+// send sends a request, and turns non-2xx responses into errors.
+func send(client *httpclient.Client, req *http.Request) error {
 	resp, err := client.Do(req)
 	if err != nil {
-		action.Errorf("Failed to send config update request: %v", err)
-		return
+		return err
 	}
 	defer resp.Body.Close()
-	action.Infof("Config update response status: %s", resp.Status)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s %s returned %s: %s", req.Method, req.URL.Path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
 }
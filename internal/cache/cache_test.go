@@ -0,0 +1,185 @@
+package cache
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/runs-on/action/internal/config"
+	"github.com/runs-on/action/internal/httpclient"
+	"github.com/sethvargo/go-githubactions"
+)
+
+func newTestClient() *httpclient.Client {
+	client := httpclient.New(nil, time.Second)
+	client.InitialBackoff = time.Millisecond
+	return client
+}
+
+func TestUpdateZctionsConfig(t *testing.T) {
+	var configAttempts atomic.Int32
+	var healthChecked atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == "/config":
+			// The cache service is still starting
+			if configAttempts.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			r.ParseForm()
+			if r.PostForm.Get("ACTIONS_RESULTS_URL") != "https://results.example.com/" || r.PostForm.Get("ACTIONS_RUNTIME_TOKEN") != "token" || r.PostForm.Get("MAGIC_CACHE") != "cache" {
+				t.Errorf("unexpected config form: %v", r.PostForm)
+			}
+		case r.Method == http.MethodGet && r.URL.Path == "/health":
+			healthChecked.Store(true)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	var out bytes.Buffer
+	action := githubactions.New(githubactions.WithWriter(&out))
+	cfg := &config.Config{ActionsResultsURL: srv.URL + "/", ZctionsResultsURL: "https://results.example.com/", ActionsRuntimeToken: "token", MagicCache: config.MagicCacheCache}
+
+	if err := UpdateZctionsConfig(action, newTestClient(), cfg); err != nil {
+		t.Fatalf("UpdateZctionsConfig() error = %v", err)
+	}
+	if configAttempts.Load() != 2 || !healthChecked.Load() {
+		t.Errorf("got %d config attempts and health checked = %t, want 2 and true", configAttempts.Load(), healthChecked.Load())
+	}
+	if strings.Contains(out.String(), "token") {
+		t.Errorf("runtime token must not be logged:\n%s", out.String())
+	}
+}
+
+func TestUpdateZctionsConfigErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    string
+	}{
+		{"config rejected", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "invalid token", http.StatusBadRequest)
+		}, "PUT /config returned 400 Bad Request: invalid token"},
+		{"unhealthy", func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/health" {
+				http.Error(w, "no backend", http.StatusInternalServerError)
+			}
+		}, "cache service is not healthy after config update: GET /health returned 500 Internal Server Error: no backend"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+
+			envFile := t.TempDir() + "/env"
+			t.Setenv("GITHUB_ENV", envFile)
+			action := githubactions.New(githubactions.WithWriter(&bytes.Buffer{}))
+			cfg := &config.Config{ActionsResultsURL: srv.URL + "/", ZctionsResultsURL: "https://results.example.com/", ZctionsCacheURL: "https://cache.example.com/"}
+			err := UpdateZctionsConfig(action, newTestClient(), cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("UpdateZctionsConfig() error = %v, want %q", err, tt.want)
+			}
+			checkGithubBackends(t, envFile)
+		})
+	}
+}
+
+func TestUpdateZctionsConfigConnectionRefused(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := srv.URL
+	srv.Close()
+
+	envFile := t.TempDir() + "/env"
+	t.Setenv("GITHUB_ENV", envFile)
+	action := githubactions.New(githubactions.WithWriter(&bytes.Buffer{}))
+	cfg := &config.Config{ActionsResultsURL: url + "/", ZctionsResultsURL: "https://results.example.com/", ZctionsCacheURL: "https://cache.example.com/"}
+	if err := UpdateZctionsConfig(action, newTestClient(), cfg); err == nil {
+		t.Errorf("expected an error when the cache service is down")
+	}
+	checkGithubBackends(t, envFile)
+}
+
+// checkGithubBackends checks that the following steps are pointed back to the GitHub backends.
+func checkGithubBackends(t *testing.T, envFile string) {
+	t.Helper()
+	env, err := os.ReadFile(envFile)
+	if err != nil {
+		t.Fatalf("failed to read GITHUB_ENV: %v", err)
+	}
+	for _, want := range []string{"ACTIONS_RESULTS_URL", "https://results.example.com/", "ACTIONS_CACHE_URL", "https://cache.example.com/"} {
+		if !strings.Contains(string(env), want) {
+			t.Errorf("GITHUB_ENV is missing %q:\n%s", want, env)
+		}
+	}
+}
+
+func TestUpdateZctionsConfigDisabled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request to the cache service: %s %s", r.Method, r.URL.Path)
+	}))
+	defer srv.Close()
+
+	envFile := t.TempDir() + "/env"
+	t.Setenv("GITHUB_ENV", envFile)
+	action := githubactions.New(githubactions.WithWriter(&bytes.Buffer{}))
+	cfg := &config.Config{ActionsResultsURL: srv.URL + "/", ZctionsResultsURL: "https://results.example.com/", ZctionsCacheURL: "https://cache.example.com/", MagicCache: config.MagicCacheNone}
+
+	if err := UpdateZctionsConfig(action, newTestClient(), cfg); err != nil {
+		t.Fatalf("UpdateZctionsConfig() error = %v", err)
+	}
+	checkGithubBackends(t, envFile)
+}
+
+func TestDisplayStats(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/stats" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"cache": {"hits": 3, "misses": 1, "bytesRestored": 1572864, "bytesSaved": 1024},
+			"artifacts": {"uploads": 2, "downloads": 0, "bytesUploaded": 2048, "bytesDownloaded": 0},
+			"throughput": {"downloadBytesPerSecond": 104857600, "uploadBytesPerSecond": 52428800}
+		}`))
+	}))
+	defer srv.Close()
+
+	summaryFile := t.TempDir() + "/summary.md"
+	t.Setenv("GITHUB_STEP_SUMMARY", summaryFile)
+	var out bytes.Buffer
+	action := githubactions.New(githubactions.WithWriter(&out))
+
+	if err := DisplayStats(action, newTestClient(), srv.URL+"/"); err != nil {
+		t.Fatalf("DisplayStats() error = %v", err)
+	}
+	summary, err := os.ReadFile(summaryFile)
+	if err != nil {
+		t.Fatalf("failed to read job summary: %v", err)
+	}
+	for _, want := range []string{"## Magic Cache Summary", "| Cache hit rate      | 75.0%       |", "| Bytes restored      | 1.5 MiB     |", "| Artifact uploads    | 2 (2.0 KiB) |", "| Download throughput | 100.0 MiB/s |"} {
+		if !strings.Contains(string(summary), want) {
+			t.Errorf("job summary is missing %q:\n%s", want, summary)
+		}
+	}
+}
+
+func TestFetchStatsError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "stats are not available", http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	_, err := FetchStats(newTestClient(), srv.URL+"/")
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("FetchStats() error = %v, want a 404 error", err)
+	}
+}
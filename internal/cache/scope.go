@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/runs-on/action/internal/config"
+	"github.com/sethvargo/go-githubactions"
+)
+
+// pullRequestEvent holds the fields of the workflow event payload needed to detect pull requests from forks.
+type pullRequestEvent struct {
+	PullRequest *struct {
+		Head struct {
+			Repo *struct {
+				FullName string `json:"full_name"`
+			} `json:"repo"`
+		} `json:"head"`
+		Base struct {
+			Repo struct {
+				FullName string `json:"full_name"`
+			} `json:"repo"`
+		} `json:"base"`
+	} `json:"pull_request"`
+}
+
+// isForkPullRequest returns true when the workflow event payload is a pull request from another repository.
+// A pull request whose head repository was deleted is considered a fork.
+func isForkPullRequest(eventPath string) (bool, error) {
+	raw, err := os.ReadFile(eventPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to read event payload: %w", err)
+	}
+	event := &pullRequestEvent{}
+	if err := json.Unmarshal(raw, event); err != nil {
+		return false, fmt.Errorf("failed to parse event payload: %w", err)
+	}
+	if event.PullRequest == nil {
+		return false, nil
+	}
+	return event.PullRequest.Head.Repo == nil || event.PullRequest.Head.Repo.FullName != event.PullRequest.Base.Repo.FullName, nil
+}
+
+// scopeBranch returns the branch cache entries are isolated to: the head branch for pull requests, the ref name otherwise.
+func scopeBranch() string {
+	if headRef := os.Getenv("GITHUB_HEAD_REF"); headRef != "" {
+		return headRef
+	}
+	return os.Getenv("GITHUB_REF_NAME")
+}
+
+// setScopeValues adds the scoping and isolation policy of the cache entries to the cache service config.
+func setScopeValues(action *githubactions.Action, cfg *config.Config, data url.Values) {
+	data.Set("MAGIC_CACHE_SCOPE", cfg.MagicCacheScope)
+	if cfg.MagicCacheScope == config.MagicCacheScopeBranch {
+		data.Set("MAGIC_CACHE_BRANCH", scopeBranch())
+	}
+	if cfg.MagicCacheNamespace != "" {
+		data.Set("MAGIC_CACHE_NAMESPACE", cfg.MagicCacheNamespace)
+	}
+
+	readOnly := false
+	if cfg.MagicCacheForkReadOnly && os.Getenv("GITHUB_EVENT_PATH") != "" {
+		fork, err := isForkPullRequest(os.Getenv("GITHUB_EVENT_PATH"))
+		if err != nil {
+			// Fail closed: an unknown event must not be able to write to the cache
+			action.Warningf("Failed to detect pull request from fork, the magic cache will be read-only: %v", err)
+			fork = true
+		}
+		readOnly = fork
+	}
+	if readOnly {
+		action.Infof("Pull request from a fork: the magic cache is read-only for this job.")
+	}
+	data.Set("MAGIC_CACHE_READ_ONLY", strconv.FormatBool(readOnly))
+}
@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"bytes"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/runs-on/action/internal/config"
+	"github.com/sethvargo/go-githubactions"
+)
+
+func writeEvent(t *testing.T, payload string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "event.json")
+	if err := os.WriteFile(path, []byte(payload), 0644); err != nil {
+		t.Fatalf("failed to write event payload: %v", err)
+	}
+	return path
+}
+
+func TestIsForkPullRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    bool
+	}{
+		{"push", `{"ref": "refs/heads/main"}`, false},
+		{"same repository", `{"pull_request": {"head": {"repo": {"full_name": "org/repo"}}, "base": {"repo": {"full_name": "org/repo"}}}}`, false},
+		{"fork", `{"pull_request": {"head": {"repo": {"full_name": "someone/repo"}}, "base": {"repo": {"full_name": "org/repo"}}}}`, true},
+		{"deleted fork", `{"pull_request": {"head": {"repo": null}, "base": {"repo": {"full_name": "org/repo"}}}}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := isForkPullRequest(writeEvent(t, tt.payload))
+			if err != nil {
+				t.Fatalf("isForkPullRequest() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("isForkPullRequest() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetScopeValues(t *testing.T) {
+	t.Setenv("GITHUB_EVENT_PATH", writeEvent(t, `{"pull_request": {"head": {"repo": {"full_name": "someone/repo"}}, "base": {"repo": {"full_name": "org/repo"}}}}`))
+	t.Setenv("GITHUB_HEAD_REF", "feature")
+	t.Setenv("GITHUB_REF_NAME", "42/merge")
+	action := githubactions.New(githubactions.WithWriter(&bytes.Buffer{}))
+
+	tests := []struct {
+		name string
+		cfg  *config.Config
+		want url.Values
+	}{
+		{
+			name: "branch scope from fork",
+			cfg:  &config.Config{MagicCacheScope: config.MagicCacheScopeBranch, MagicCacheForkReadOnly: true},
+			want: url.Values{"MAGIC_CACHE_SCOPE": {"branch"}, "MAGIC_CACHE_BRANCH": {"feature"}, "MAGIC_CACHE_READ_ONLY": {"true"}},
+		},
+		{
+			name: "shared namespace with writable forks",
+			cfg:  &config.Config{MagicCacheScope: config.MagicCacheScopeRepo, MagicCacheNamespace: "shared"},
+			want: url.Values{"MAGIC_CACHE_SCOPE": {"repo"}, "MAGIC_CACHE_NAMESPACE": {"shared"}, "MAGIC_CACHE_READ_ONLY": {"false"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := url.Values{}
+			setScopeValues(action, tt.cfg, data)
+			if data.Encode() != tt.want.Encode() {
+				t.Errorf("setScopeValues() = %v, want %v", data, tt.want)
+			}
+		})
+	}
+}
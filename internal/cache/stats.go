@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/runs-on/action/internal/httpclient"
+	"github.com/runs-on/action/internal/utils"
+	"github.com/sethvargo/go-githubactions"
+)
+
+// Stats holds the per-job statistics of the local RunsOn cache service.
+type Stats struct {
+	Cache struct {
+		Hits          int64 `json:"hits"`
+		Misses        int64 `json:"misses"`
+		BytesRestored int64 `json:"bytesRestored"`
+		BytesSaved    int64 `json:"bytesSaved"`
+	} `json:"cache"`
+	Artifacts struct {
+		Uploads         int64 `json:"uploads"`
+		Downloads       int64 `json:"downloads"`
+		BytesUploaded   int64 `json:"bytesUploaded"`
+		BytesDownloaded int64 `json:"bytesDownloaded"`
+	} `json:"artifacts"`
+	Throughput struct {
+		DownloadBytesPerSecond float64 `json:"downloadBytesPerSecond"`
+		UploadBytesPerSecond   float64 `json:"uploadBytesPerSecond"`
+	} `json:"throughput"`
+}
+
+// HitRate returns the percentage of cache lookups that were hits.
+func (s *Stats) HitRate() float64 {
+	lookups := s.Cache.Hits + s.Cache.Misses
+	if lookups == 0 {
+		return 0
+	}
+	return float64(s.Cache.Hits) / float64(lookups) * 100
+}
+
+// FetchStats retrieves the statistics of the current job from the stats endpoint of the local RunsOn cache service.
+func FetchStats(client *httpclient.Client, baseURL string) (*Stats, error) {
+	req, err := http.NewRequest(http.MethodGet, baseURL+"stats", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stats request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stats response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("%s %s returned %s: %s", req.Method, req.URL.Path, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	stats := &Stats{}
+	if err := json.Unmarshal(body, stats); err != nil {
+		return nil, fmt.Errorf("failed to parse stats response: %w", err)
+	}
+	return stats, nil
+}
+
+// DisplayStats writes the magic cache statistics of the current job to the log and the job summary.
+func DisplayStats(action *githubactions.Action, client *httpclient.Client, baseURL string) error {
+	stats, err := FetchStats(client, baseURL)
+	if err != nil {
+		return fmt.Errorf("failed to fetch cache service stats: %w", err)
+	}
+
+	summary := renderStats(stats)
+	fmt.Print(summary)
+	action.AddStepSummary(summary)
+	action.Infof("Magic cache statistics added to job summary.")
+	return nil
+}
+
+func renderStats(stats *Stats) string {
+	rows := [][]string{
+		{"Cache hits", fmt.Sprintf("%d", stats.Cache.Hits)},
+		{"Cache misses", fmt.Sprintf("%d", stats.Cache.Misses)},
+		{"Cache hit rate", fmt.Sprintf("%.1f%%", stats.HitRate())},
+		{"Bytes restored", utils.FormatBytes(float64(stats.Cache.BytesRestored))},
+		{"Bytes saved", utils.FormatBytes(float64(stats.Cache.BytesSaved))},
+		{"Artifact uploads", fmt.Sprintf("%d (%s)", stats.Artifacts.Uploads, utils.FormatBytes(float64(stats.Artifacts.BytesUploaded)))},
+		{"Artifact downloads", fmt.Sprintf("%d (%s)", stats.Artifacts.Downloads, utils.FormatBytes(float64(stats.Artifacts.BytesDownloaded)))},
+		{"Download throughput", utils.FormatBytes(stats.Throughput.DownloadBytesPerSecond) + "/s"},
+		{"Upload throughput", utils.FormatBytes(stats.Throughput.UploadBytesPerSecond) + "/s"},
+	}
+
+	b := &strings.Builder{}
+	b.WriteString("## Magic Cache Summary\n\n")
+	b.WriteString(utils.RenderMarkdownTable([]string{"metric", "value"}, rows))
+	b.WriteString("\n")
+	return b.String()
+}
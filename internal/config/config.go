@@ -1,26 +1,103 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/sethvargo/go-githubactions"
 )
 
+// Modes that run a single feature as a regular step, instead of the default main and post logic.
+const (
+	ModeCosts      = "costs"
+	ModeRollup     = "rollup"
+	ModeCacheAdmin = "cache_admin"
+	ModeCacheUsage = "cache_usage"
+)
+
+// Actions of the cache_admin mode.
+const (
+	CacheAdminActionList  = "list"
+	CacheAdminActionPurge = "purge"
+)
+
+// Actions taken when the job cost exceeds the cost budget.
+const (
+	CostBudgetActionWarn = "warn"
+	CostBudgetActionFail = "fail"
+)
+
+// Traffic redirected to the magic cache service, as selected by the 'magic_cache' input.
+const (
+	MagicCacheAll       = "all"
+	MagicCacheCache     = "cache"
+	MagicCacheArtifacts = "artifacts"
+	MagicCacheNone      = "none"
+)
+
+// Scopes of the magic cache entries, as selected by the 'magic_cache_scope' input.
+const (
+	MagicCacheScopeRepo   = "repo"
+	MagicCacheScopeBranch = "branch"
+)
+
 // Config holds the action's configuration values derived from inputs and environment.
 type Config struct {
-	ShowEnv             bool
-	ShowCosts           string
-	Metrics             []string
-	NetworkInterface    string
-	DiskDevice          string
-	Sccache             string
-	ZctionsResultsURL   string
-	ZctionsCacheURL     string
-	ActionsResultsURL   string
-	ActionsRuntimeToken string
+	Mode                     string
+	ShowEnv                  bool
+	ShowCosts                string
+	ShowTimeline             bool
+	ShowCarbon               bool
+	CostDiscounts            string
+	CostDiscountsFile        string
+	GithubRunner             string
+	GithubRunners            []string
+	GithubRatesFile          string
+	CostAlternatives         bool
+	CostAlternativeFamilies  []string
+	CostReport               string
+	CostLabel                string
+	CostBudget               float64
+	CostBudgetAction         string
+	CostBudgetTimeoutMinutes int
+	PricingDataset           string
+	StorageCosts             bool
+	DataTransfer             string
+	Metrics                  []string
+	MetricsThresholds        []float64
+	MetricsCPUStacked        bool
+	NetworkInterface         string
+	DiskDevice               string
+	Sccache                  string
+	HTTPTimeout              time.Duration
+	SpotWatcher              bool
+	SpotMarkerFile           string
+	SpotHook                 string
+	MagicCache               string
+	MagicCacheRequired       bool
+	S3Endpoint               string
+	S3PathStyle              bool
+	CachePaths               []string
+	CacheKey                 string
+	CacheRestoreKeys         []string
+	CacheUsage               bool
+	CacheQuota               int64
+	CacheAdminAction         string
+	CacheAdminPrefix         string
+	CacheAdminOlderThan      time.Duration
+	MagicCacheScope          string
+	MagicCacheForkReadOnly   bool
+	MagicCacheNamespace      string
+	ZctionsResultsURL        string
+	ZctionsCacheURL          string
+	ActionsResultsURL        string
+	ActionsRuntimeToken      string
 }
 
 type Tag struct {
@@ -32,6 +109,8 @@ type Tag struct {
 func NewConfigFromInputs(action *githubactions.Action) (*Config, error) {
 	cfg := &Config{}
 
+	cfg.Mode = action.GetInput("mode")
+
 	showEnvStr := action.GetInput("show_env")
 	if showEnvStr != "" {
 		var err error
@@ -46,11 +125,151 @@ func NewConfigFromInputs(action *githubactions.Action) (*Config, error) {
 		cfg.ShowCosts = "inline"
 	}
 
+	showTimelineStr := action.GetInput("show_timeline")
+	if showTimelineStr != "" {
+		var err error
+		cfg.ShowTimeline, err = strconv.ParseBool(showTimelineStr)
+		if err != nil {
+			action.Warningf("Error parsing 'show_timeline' input '%s': %v. Assuming false.", showTimelineStr, err)
+		}
+	}
+
+	showCarbonStr := action.GetInput("show_carbon")
+	if showCarbonStr != "" {
+		var err error
+		cfg.ShowCarbon, err = strconv.ParseBool(showCarbonStr)
+		if err != nil {
+			action.Warningf("Error parsing 'show_carbon' input '%s': %v. Assuming false.", showCarbonStr, err)
+		}
+	}
+
+	cfg.CostDiscounts = action.GetInput("cost_discounts")
+	cfg.CostDiscountsFile = action.GetInput("cost_discounts_file")
+
+	cfg.GithubRunner = action.GetInput("github_runner")
+	githubRunnersInput := action.GetInput("github_runners")
+	if githubRunnersInput != "" {
+		cfg.GithubRunners = strings.Split(strings.ReplaceAll(githubRunnersInput, " ", ""), ",")
+	}
+	cfg.GithubRatesFile = action.GetInput("github_rates_file")
+
+	cfg.CostAlternatives = true
+	costAlternativesStr := action.GetInput("cost_alternatives")
+	if costAlternativesStr != "" {
+		var err error
+		cfg.CostAlternatives, err = strconv.ParseBool(costAlternativesStr)
+		if err != nil {
+			action.Warningf("Error parsing 'cost_alternatives' input '%s': %v. Assuming true.", costAlternativesStr, err)
+			cfg.CostAlternatives = true
+		}
+	}
+
+	costAlternativeFamiliesInput := action.GetInput("cost_alternative_families")
+	if costAlternativeFamiliesInput != "" {
+		cfg.CostAlternativeFamilies = strings.Split(strings.ReplaceAll(costAlternativeFamiliesInput, " ", ""), ",")
+	}
+
+	cfg.CostReport = action.GetInput("cost_report")
+	if cfg.CostReport == "" {
+		cfg.CostReport = filepath.Join(os.TempDir(), "runs-on-cost-report.json")
+		if runnerTemp := os.Getenv("RUNNER_TEMP"); runnerTemp != "" {
+			cfg.CostReport = filepath.Join(runnerTemp, "runs-on-cost-report.json")
+		}
+	}
+
+	cfg.CostLabel = action.GetInput("cost_label")
+
+	costBudgetStr := action.GetInput("cost_budget")
+	if costBudgetStr != "" {
+		var err error
+		cfg.CostBudget, err = strconv.ParseFloat(strings.TrimPrefix(costBudgetStr, "$"), 64)
+		if err == nil && cfg.CostBudget < 0 {
+			err = errors.New("must not be negative")
+		}
+		if err != nil {
+			action.Warningf("Error parsing 'cost_budget' input '%s': %v. Assuming no budget.", costBudgetStr, err)
+			cfg.CostBudget = 0
+		}
+	}
+
+	cfg.CostBudgetAction = action.GetInput("cost_budget_action")
+	switch cfg.CostBudgetAction {
+	case CostBudgetActionWarn, CostBudgetActionFail:
+	case "":
+		cfg.CostBudgetAction = CostBudgetActionWarn
+	default:
+		action.Warningf("Unsupported 'cost_budget_action' input '%s'. Expected warn or fail. Assuming warn.", cfg.CostBudgetAction)
+		cfg.CostBudgetAction = CostBudgetActionWarn
+	}
+
+	// GitHub does not expose the job timeout to steps, so it has to be given as an input
+	cfg.CostBudgetTimeoutMinutes = 360
+	costBudgetTimeoutStr := action.GetInput("cost_budget_timeout_minutes")
+	if costBudgetTimeoutStr != "" {
+		timeout, err := strconv.Atoi(costBudgetTimeoutStr)
+		if err == nil && timeout <= 0 {
+			err = errors.New("must be positive")
+		}
+		if err != nil {
+			action.Warningf("Error parsing 'cost_budget_timeout_minutes' input '%s': %v. Assuming 360.", costBudgetTimeoutStr, err)
+		} else {
+			cfg.CostBudgetTimeoutMinutes = timeout
+		}
+	}
+
+	cfg.PricingDataset = action.GetInput("pricing_dataset")
+
+	cfg.StorageCosts = true
+	storageCostsStr := action.GetInput("storage_costs")
+	if storageCostsStr != "" {
+		var err error
+		cfg.StorageCosts, err = strconv.ParseBool(storageCostsStr)
+		if err != nil {
+			action.Warningf("Error parsing 'storage_costs' input '%s': %v. Assuming true.", storageCostsStr, err)
+			cfg.StorageCosts = true
+		}
+	}
+
+	cfg.DataTransfer = action.GetInput("data_transfer")
+	switch cfg.DataTransfer {
+	case "nat", "cross-az", "none":
+	case "":
+		cfg.DataTransfer = "none"
+	default:
+		action.Warningf("Unsupported 'data_transfer' input '%s'. Expected nat, cross-az or none. Assuming none.", cfg.DataTransfer)
+		cfg.DataTransfer = "none"
+	}
+
 	metricsInput := action.GetInput("metrics")
 	if metricsInput != "" {
 		cfg.Metrics = strings.Split(strings.ReplaceAll(metricsInput, " ", ""), ",")
 	}
 
+	thresholdsInput := action.GetInput("metrics_thresholds")
+	if thresholdsInput == "" {
+		thresholdsInput = "90"
+	}
+	for _, raw := range strings.Split(strings.ReplaceAll(thresholdsInput, " ", ""), ",") {
+		if raw == "" {
+			continue
+		}
+		threshold, err := strconv.ParseFloat(strings.TrimSuffix(raw, "%"), 64)
+		if err != nil {
+			action.Warningf("Error parsing 'metrics_thresholds' value '%s': %v. Ignoring it.", raw, err)
+			continue
+		}
+		cfg.MetricsThresholds = append(cfg.MetricsThresholds, threshold)
+	}
+
+	cpuStackedStr := action.GetInput("metrics_cpu_stacked")
+	if cpuStackedStr != "" {
+		var err error
+		cfg.MetricsCPUStacked, err = strconv.ParseBool(cpuStackedStr)
+		if err != nil {
+			action.Warningf("Error parsing 'metrics_cpu_stacked' input '%s': %v. Assuming false.", cpuStackedStr, err)
+		}
+	}
+
 	cfg.NetworkInterface = action.GetInput("network_interface")
 	if cfg.NetworkInterface == "" {
 		cfg.NetworkInterface = "auto"
@@ -63,17 +282,199 @@ func NewConfigFromInputs(action *githubactions.Action) (*Config, error) {
 
 	cfg.Sccache = action.GetInput("sccache")
 
+	cfg.HTTPTimeout = 10 * time.Second
+	httpTimeoutStr := action.GetInput("http_timeout")
+	if httpTimeoutStr != "" {
+		seconds, err := strconv.ParseFloat(httpTimeoutStr, 64)
+		if err == nil && seconds <= 0 {
+			err = errors.New("must be positive")
+		}
+		if err != nil {
+			action.Warningf("Error parsing 'http_timeout' input '%s': %v. Assuming 10.", httpTimeoutStr, err)
+		} else {
+			cfg.HTTPTimeout = time.Duration(seconds * float64(time.Second))
+		}
+	}
+
+	cfg.SpotWatcher = true
+	spotWatcherStr := action.GetInput("spot_watcher")
+	if spotWatcherStr != "" {
+		var err error
+		cfg.SpotWatcher, err = strconv.ParseBool(spotWatcherStr)
+		if err != nil {
+			action.Warningf("Error parsing 'spot_watcher' input '%s': %v. Assuming true.", spotWatcherStr, err)
+			cfg.SpotWatcher = true
+		}
+	}
+
+	cfg.SpotMarkerFile = action.GetInput("spot_marker_file")
+	if cfg.SpotMarkerFile == "" {
+		cfg.SpotMarkerFile = filepath.Join(os.TempDir(), "runs-on-spot-notice.json")
+		if runnerTemp := os.Getenv("RUNNER_TEMP"); runnerTemp != "" {
+			cfg.SpotMarkerFile = filepath.Join(runnerTemp, "runs-on-spot-notice.json")
+		}
+	}
+
+	cfg.SpotHook = action.GetInput("spot_hook")
+
+	cfg.MagicCache = action.GetInput("magic_cache")
+	switch cfg.MagicCache {
+	case MagicCacheAll, MagicCacheCache, MagicCacheArtifacts, MagicCacheNone:
+	case "":
+		cfg.MagicCache = MagicCacheAll
+	default:
+		action.Warningf("Unsupported 'magic_cache' input '%s'. Expected all, cache, artifacts or none. Assuming all.", cfg.MagicCache)
+		cfg.MagicCache = MagicCacheAll
+	}
+
+	magicCacheRequiredStr := action.GetInput("magic_cache_required")
+	if magicCacheRequiredStr != "" {
+		var err error
+		cfg.MagicCacheRequired, err = strconv.ParseBool(magicCacheRequiredStr)
+		if err != nil {
+			action.Warningf("Error parsing 'magic_cache_required' input '%s': %v. Assuming false.", magicCacheRequiredStr, err)
+		}
+	}
+
+	cfg.MagicCacheScope = action.GetInput("magic_cache_scope")
+	switch cfg.MagicCacheScope {
+	case MagicCacheScopeRepo, MagicCacheScopeBranch:
+	case "":
+		cfg.MagicCacheScope = MagicCacheScopeRepo
+	default:
+		action.Warningf("Unsupported 'magic_cache_scope' input '%s'. Expected repo or branch. Assuming repo.", cfg.MagicCacheScope)
+		cfg.MagicCacheScope = MagicCacheScopeRepo
+	}
+
+	// Pull requests from forks must not be able to write cache entries restored by trusted builds
+	cfg.MagicCacheForkReadOnly = true
+	magicCacheForkReadOnlyStr := action.GetInput("magic_cache_fork_readonly")
+	if magicCacheForkReadOnlyStr != "" {
+		var err error
+		cfg.MagicCacheForkReadOnly, err = strconv.ParseBool(magicCacheForkReadOnlyStr)
+		if err != nil {
+			action.Warningf("Error parsing 'magic_cache_fork_readonly' input '%s': %v. Assuming true.", magicCacheForkReadOnlyStr, err)
+			cfg.MagicCacheForkReadOnly = true
+		}
+	}
+
+	cfg.MagicCacheNamespace = action.GetInput("magic_cache_namespace")
+
+	cfg.S3Endpoint = strings.TrimSuffix(strings.TrimSpace(action.GetInput("s3_endpoint")), "/")
+	if cfg.S3Endpoint != "" && !strings.HasPrefix(cfg.S3Endpoint, "http://") && !strings.HasPrefix(cfg.S3Endpoint, "https://") {
+		cfg.S3Endpoint = "https://" + cfg.S3Endpoint
+	}
+
+	// S3-compatible servers such as MinIO and VPC endpoints usually require path-style addressing
+	cfg.S3PathStyle = true
+	s3PathStyleStr := action.GetInput("s3_path_style")
+	if s3PathStyleStr != "" {
+		var err error
+		cfg.S3PathStyle, err = strconv.ParseBool(s3PathStyleStr)
+		if err != nil {
+			action.Warningf("Error parsing 's3_path_style' input '%s': %v. Assuming true.", s3PathStyleStr, err)
+			cfg.S3PathStyle = true
+		}
+	}
+
+	cfg.CachePaths = splitList(action.GetInput("cache_paths"))
+	cfg.CacheKey = strings.TrimSpace(action.GetInput("cache_key"))
+	cfg.CacheRestoreKeys = splitList(action.GetInput("cache_restore_keys"))
+	if len(cfg.CachePaths) > 0 && cfg.CacheKey == "" {
+		action.Warningf("Input 'cache_paths' is set without 'cache_key'. Directories will not be cached.")
+	}
+
+	cacheUsageStr := action.GetInput("cache_usage")
+	if cacheUsageStr != "" {
+		var err error
+		cfg.CacheUsage, err = strconv.ParseBool(cacheUsageStr)
+		if err != nil {
+			action.Warningf("Error parsing 'cache_usage' input '%s': %v. Assuming false.", cacheUsageStr, err)
+		}
+	}
+
+	cacheQuotaStr := action.GetInput("cache_quota")
+	if cacheQuotaStr != "" {
+		quota, err := parseSize(cacheQuotaStr)
+		if err != nil || quota <= 0 {
+			action.Warningf("Error parsing 'cache_quota' input '%s': expected a size such as 50GiB. Ignoring quota.", cacheQuotaStr)
+		} else {
+			cfg.CacheQuota = quota
+		}
+	}
+
+	cfg.CacheAdminAction = action.GetInput("cache_admin_action")
+	switch cfg.CacheAdminAction {
+	case CacheAdminActionList, CacheAdminActionPurge:
+	case "":
+		cfg.CacheAdminAction = CacheAdminActionList
+	default:
+		action.Warningf("Unsupported 'cache_admin_action' input '%s'. Expected list or purge. Assuming list.", cfg.CacheAdminAction)
+		cfg.CacheAdminAction = CacheAdminActionList
+	}
+
+	cfg.CacheAdminPrefix = action.GetInput("cache_admin_prefix")
+
+	cacheAdminOlderThanStr := action.GetInput("cache_admin_older_than")
+	if cacheAdminOlderThanStr != "" {
+		olderThan, err := parseAge(cacheAdminOlderThanStr)
+		if err != nil || olderThan <= 0 {
+			// An invalid age must not turn a purge by age into a purge of everything under the prefix
+			return nil, fmt.Errorf("invalid 'cache_admin_older_than' input '%s': expected a duration such as 12h or 7d", cacheAdminOlderThanStr)
+		}
+		cfg.CacheAdminOlderThan = olderThan
+	}
+
 	cfg.ZctionsResultsURL = os.Getenv("ZCTIONS_RESULTS_URL")
 	cfg.ZctionsCacheURL = os.Getenv("ZCTIONS_CACHE_URL")
 	cfg.ActionsResultsURL = os.Getenv("ACTIONS_RESULTS_URL")
 	cfg.ActionsRuntimeToken = os.Getenv("ACTIONS_RUNTIME_TOKEN")
 
+	action.Infof("Input 'mode': %s", cfg.Mode)
 	action.Infof("Input 'show_env': %t", cfg.ShowEnv)
 	action.Infof("Input 'show_costs': %s", cfg.ShowCosts)
+	action.Infof("Input 'show_timeline': %t", cfg.ShowTimeline)
+	action.Infof("Input 'show_carbon': %t", cfg.ShowCarbon)
+	action.Infof("Input 'cost_discounts': %s", cfg.CostDiscounts)
+	action.Infof("Input 'cost_discounts_file': %s", cfg.CostDiscountsFile)
+	action.Infof("Input 'github_runner': %s", cfg.GithubRunner)
+	action.Infof("Input 'github_runners': %v", cfg.GithubRunners)
+	action.Infof("Input 'github_rates_file': %s", cfg.GithubRatesFile)
+	action.Infof("Input 'cost_alternatives': %t", cfg.CostAlternatives)
+	action.Infof("Input 'cost_alternative_families': %v", cfg.CostAlternativeFamilies)
+	action.Infof("Input 'cost_report': %s", cfg.CostReport)
+	action.Infof("Input 'cost_label': %s", cfg.CostLabel)
+	action.Infof("Input 'cost_budget': %.2f", cfg.CostBudget)
+	action.Infof("Input 'cost_budget_action': %s", cfg.CostBudgetAction)
+	action.Infof("Input 'cost_budget_timeout_minutes': %d", cfg.CostBudgetTimeoutMinutes)
+	action.Infof("Input 'pricing_dataset': %s", cfg.PricingDataset)
+	action.Infof("Input 'storage_costs': %t", cfg.StorageCosts)
+	action.Infof("Input 'data_transfer': %s", cfg.DataTransfer)
 	action.Infof("Input 'metrics': %v", cfg.Metrics)
+	action.Infof("Input 'metrics_thresholds': %v", cfg.MetricsThresholds)
+	action.Infof("Input 'metrics_cpu_stacked': %t", cfg.MetricsCPUStacked)
 	action.Infof("Input 'network_interface': %s", cfg.NetworkInterface)
 	action.Infof("Input 'disk_device': %s", cfg.DiskDevice)
 	action.Infof("Input 'sccache': %s", cfg.Sccache)
+	action.Infof("Input 'magic_cache': %s", cfg.MagicCache)
+	action.Infof("Input 'magic_cache_required': %t", cfg.MagicCacheRequired)
+	action.Infof("Input 'magic_cache_scope': %s", cfg.MagicCacheScope)
+	action.Infof("Input 'magic_cache_fork_readonly': %t", cfg.MagicCacheForkReadOnly)
+	action.Infof("Input 'magic_cache_namespace': %s", cfg.MagicCacheNamespace)
+	action.Infof("Input 's3_endpoint': %s", cfg.S3Endpoint)
+	action.Infof("Input 's3_path_style': %t", cfg.S3PathStyle)
+	action.Infof("Input 'cache_paths': %v", cfg.CachePaths)
+	action.Infof("Input 'cache_key': %s", cfg.CacheKey)
+	action.Infof("Input 'cache_restore_keys': %v", cfg.CacheRestoreKeys)
+	action.Infof("Input 'cache_usage': %t", cfg.CacheUsage)
+	action.Infof("Input 'cache_quota': %d bytes", cfg.CacheQuota)
+	action.Infof("Input 'cache_admin_action': %s", cfg.CacheAdminAction)
+	action.Infof("Input 'cache_admin_prefix': %s", cfg.CacheAdminPrefix)
+	action.Infof("Input 'cache_admin_older_than': %s", cfg.CacheAdminOlderThan)
+	action.Infof("Input 'http_timeout': %s", cfg.HTTPTimeout)
+	action.Infof("Input 'spot_watcher': %t", cfg.SpotWatcher)
+	action.Infof("Input 'spot_marker_file': %s", cfg.SpotMarkerFile)
+	action.Infof("Input 'spot_hook': %s", cfg.SpotHook)
 
 	if cfg.ZctionsResultsURL != "" {
 		action.Infof("ZCTIONS_RESULTS_URL is set: %s", cfg.ZctionsResultsURL)
@@ -102,6 +503,24 @@ func (c *Config) HasShowCosts() bool {
 	return c.ShowCosts != "inline"
 }
 
+func (c *Config) HasCostBudget() bool {
+	return c.CostBudget > 0
+}
+
+// HasMagicCache returns true when the magic cache is enabled for the runner, and not switched off with the 'magic_cache' input.
+func (c *Config) HasMagicCache() bool {
+	return c.ZctionsResultsURL != "" && c.MagicCache != MagicCacheNone
+}
+
+// HasDirectoryCache returns true when directories are cached in the RunsOn S3 cache bucket, with the 'cache_paths' and 'cache_key' inputs.
+func (c *Config) HasDirectoryCache() bool {
+	return c.IsUsingRunsOn() && len(c.CachePaths) > 0 && c.CacheKey != ""
+}
+
+func (c *Config) HasCacheUsage() bool {
+	return c.IsUsingRunsOn() && c.CacheUsage
+}
+
 func (c *Config) HasMetrics() bool {
 	return c.IsUsingRunsOn() && c.IsUsingLinux() && len(c.Metrics) > 0
 }
@@ -110,6 +529,10 @@ func (c *Config) HasSccache() bool {
 	return c.IsUsingRunsOn() && c.IsUsingLinux() && c.Sccache != ""
 }
 
+func (c *Config) HasSpotWatcher() bool {
+	return c.IsUsingRunsOn() && c.SpotWatcher && os.Getenv("RUNS_ON_INSTANCE_LIFECYCLE") == "spot"
+}
+
 func (c *Config) IsUsingRunsOn() bool {
 	return os.Getenv("RUNS_ON_RUNNER_NAME") != ""
 }
@@ -117,3 +540,50 @@ func (c *Config) IsUsingRunsOn() bool {
 func (c *Config) IsUsingLinux() bool {
 	return runtime.GOOS == "linux"
 }
+
+// splitList splits a multi-line or comma-separated input, ignoring blank entries.
+func splitList(input string) []string {
+	items := []string{}
+	for _, item := range strings.FieldsFunc(input, func(r rune) bool { return r == '\n' || r == ',' }) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// sizeUnits are the multipliers of the size suffixes, longest suffixes first.
+var sizeUnits = []struct {
+	suffix     string
+	multiplier float64
+}{
+	{"TiB", 1 << 40}, {"GiB", 1 << 30}, {"MiB", 1 << 20}, {"KiB", 1 << 10},
+	{"TB", 1e12}, {"GB", 1e9}, {"MB", 1e6}, {"KB", 1e3}, {"B", 1},
+}
+
+// parseSize parses a size in bytes, with an optional decimal (GB) or binary (GiB) unit suffix.
+func parseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	for _, unit := range sizeUnits {
+		if number, ok := strings.CutSuffix(s, unit.suffix); ok {
+			n, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+			if err != nil {
+				return 0, err
+			}
+			return int64(n * unit.multiplier), nil
+		}
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// parseAge parses a duration such as 12h, with support for a number of days such as 7d.
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n * float64(24*time.Hour)), nil
+	}
+	return time.ParseDuration(s)
+}
@@ -0,0 +1,201 @@
+package costs
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/runs-on/action/internal/utils"
+)
+
+// Instance sizes, from smallest to largest.
+var instanceSizes = []string{"nano", "micro", "small", "medium", "large", "xlarge", "2xlarge", "3xlarge", "4xlarge", "6xlarge", "8xlarge", "9xlarge", "12xlarge", "16xlarge", "18xlarge", "24xlarge", "32xlarge", "48xlarge", "metal"}
+
+// Instance families look like m7i, m7i-flex or t4g: class, generation, then attributes.
+var instanceFamilyPattern = regexp.MustCompile(`^([a-z]+?)(\d+)([a-z0-9-]*)$`)
+
+// Label of the current instance among the alternatives, which the other costs are compared with.
+const currentAlternativeLabel = "Current"
+
+// costAlternative is the cost of the job duration on another instance type or lifecycle.
+type costAlternative struct {
+	Label        string
+	InstanceType string
+	Lifecycle    string
+	Instance     InstancePricing
+	Cost         float64
+}
+
+func splitInstanceType(instanceType string) (family, size string) {
+	family, size, _ = strings.Cut(instanceType, ".")
+	return family, size
+}
+
+func sizeIndex(size string) int {
+	for i, s := range instanceSizes {
+		if s == size {
+			return i
+		}
+	}
+	return -1
+}
+
+// equivalentArchInstance returns the instance type of the same class and size with the other architecture,
+// preferring the closest generation, then regular over flex instances, then Intel over AMD.
+func (d *PricingDataset) equivalentArchInstance(region, instanceType, arch string) (string, bool) {
+	family, size := splitInstanceType(instanceType)
+	match := instanceFamilyPattern.FindStringSubmatch(family)
+	if match == nil {
+		return "", false
+	}
+	class, generation := match[1], match[2]
+	gen, _ := strconv.Atoi(generation)
+
+	best, bestScore := "", -1
+	for candidate, pricing := range d.Regions[region].Instances {
+		if pricing.Arch == arch || pricing.Arch == "" {
+			continue
+		}
+		candidateFamily, candidateSize := splitInstanceType(candidate)
+		candidateMatch := instanceFamilyPattern.FindStringSubmatch(candidateFamily)
+		if candidateSize != size || candidateMatch == nil || candidateMatch[1] != class {
+			continue
+		}
+		candidateGen, _ := strconv.Atoi(candidateMatch[2])
+		score := 100 * max(gen-candidateGen, candidateGen-gen)
+		if strings.Contains(candidateMatch[3], "flex") {
+			score += 10
+		}
+		if strings.HasPrefix(candidateMatch[3], "a") {
+			score++
+		}
+		if best == "" || score < bestScore || score == bestScore && candidate < best {
+			best, bestScore = candidate, score
+		}
+	}
+	return best, best != ""
+}
+
+// neighbourSizes returns the next size down and up of an instance type available in the dataset, if any.
+func (d *PricingDataset) neighbourSizes(region, instanceType string) (down, up string) {
+	family, size := splitInstanceType(instanceType)
+	current := sizeIndex(size)
+	if current < 0 {
+		return "", ""
+	}
+	for i := current - 1; i >= 0 && down == ""; i-- {
+		if _, ok := d.Regions[region].Instances[family+"."+instanceSizes[i]]; ok {
+			down = family + "." + instanceSizes[i]
+		}
+	}
+	for i := current + 1; i < len(instanceSizes) && up == ""; i++ {
+		if _, ok := d.Regions[region].Instances[family+"."+instanceSizes[i]]; ok {
+			up = family + "." + instanceSizes[i]
+		}
+	}
+	return down, up
+}
+
+// computeAlternatives computes what the job duration would cost on other instance types and lifecycles:
+// the other architecture, the other lifecycle, the next size down and up, and the same size in the given families.
+func (d *PricingDataset) computeAlternatives(payload CostRequestPayload, durationMinutes float64, families []string) ([]costAlternative, error) {
+	current, err := d.Instance(payload.Region, payload.InstanceType)
+	if err != nil {
+		return nil, err
+	}
+	family, size := splitInstanceType(payload.InstanceType)
+
+	type candidate struct{ label, instanceType, lifecycle string }
+	candidates := []candidate{{currentAlternativeLabel, payload.InstanceType, payload.InstanceLifecycle}}
+	if equivalent, ok := d.equivalentArchInstance(payload.Region, payload.InstanceType, current.Arch); ok {
+		otherArch := "arm64"
+		if current.Arch == "arm64" {
+			otherArch = "x64"
+		}
+		candidates = append(candidates, candidate{otherArch + " equivalent", equivalent, payload.InstanceLifecycle})
+	}
+	otherLifecycle := "spot"
+	if payload.InstanceLifecycle == "spot" {
+		otherLifecycle = "on-demand"
+	}
+	candidates = append(candidates, candidate{"Other lifecycle", payload.InstanceType, otherLifecycle})
+	down, up := d.neighbourSizes(payload.Region, payload.InstanceType)
+	if down != "" {
+		candidates = append(candidates, candidate{"Size down", down, payload.InstanceLifecycle})
+	}
+	if up != "" {
+		candidates = append(candidates, candidate{"Size up", up, payload.InstanceLifecycle})
+	}
+	for _, alternativeFamily := range families {
+		if alternativeFamily != "" && alternativeFamily != family {
+			candidates = append(candidates, candidate{"Family " + alternativeFamily, alternativeFamily + "." + size, payload.InstanceLifecycle})
+		}
+	}
+
+	alternatives := []costAlternative{}
+	seen := map[string]bool{}
+	for _, c := range candidates {
+		if seen[c.instanceType+"/"+c.lifecycle] {
+			continue
+		}
+		seen[c.instanceType+"/"+c.lifecycle] = true
+
+		hourlyPrice, err := d.HourlyPrice(payload.Region, c.instanceType, c.lifecycle, payload.Az, payload.ZoneId)
+		if err != nil {
+			// Without the current instance, there is nothing to compare the alternatives with
+			if c.label == currentAlternativeLabel {
+				return nil, err
+			}
+			continue
+		}
+		instance, _ := d.Instance(payload.Region, c.instanceType)
+		alternatives = append(alternatives, costAlternative{
+			Label:        c.label,
+			InstanceType: c.instanceType,
+			Lifecycle:    c.lifecycle,
+			Instance:     instance,
+			Cost:         hourlyPrice * durationMinutes / 60,
+		})
+	}
+	// Keep the current instance first, then sort by cost
+	if len(alternatives) > 1 {
+		sort.SliceStable(alternatives[1:], func(i, j int) bool { return alternatives[i+1].Cost < alternatives[j+1].Cost })
+	}
+	return alternatives, nil
+}
+
+// renderAlternatives renders the alternatives as a markdown table, with the cost difference against the current instance.
+func renderAlternatives(alternatives []costAlternative) (string, error) {
+	currentIndex := slices.IndexFunc(alternatives, func(alternative costAlternative) bool { return alternative.Label == currentAlternativeLabel })
+	if currentIndex < 0 {
+		return "", fmt.Errorf("no cost for the current instance to compare the alternatives with")
+	}
+	current := alternatives[currentIndex]
+
+	headers := []string{"alternative", "instance type", "lifecycle", "arch", "vCPUs", "memory", "cost", "difference"}
+	rows := [][]string{}
+	for i, alternative := range alternatives {
+		difference := "-"
+		if i != currentIndex && current.Cost > 0 {
+			difference = fmt.Sprintf("%+.1f%%", (alternative.Cost-current.Cost)/current.Cost*100)
+		}
+		rows = append(rows, []string{
+			alternative.Label,
+			alternative.InstanceType,
+			alternative.Lifecycle,
+			alternative.Instance.Arch,
+			strconv.Itoa(alternative.Instance.VCPUs),
+			fmt.Sprintf("%g GiB", alternative.Instance.MemoryGiB),
+			fmt.Sprintf("$%.4f", alternative.Cost),
+			difference,
+		})
+	}
+
+	b := &strings.Builder{}
+	b.WriteString("### What-if cost comparison\n\n")
+	b.WriteString(utils.RenderMarkdownTable(headers, rows))
+	return b.String(), nil
+}
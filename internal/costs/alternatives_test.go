@@ -0,0 +1,91 @@
+package costs
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestComputeAlternatives(t *testing.T) {
+	dataset, err := LoadPricingDataset(t.Context(), "")
+	if err != nil {
+		t.Fatalf("LoadPricingDataset() error = %v", err)
+	}
+	payload := CostRequestPayload{InstanceType: "m7i.xlarge", InstanceLifecycle: "spot", Region: "us-east-1"}
+
+	alternatives, err := dataset.computeAlternatives(payload, 60, []string{"c7g", "m7i", "unknown"})
+	if err != nil {
+		t.Fatalf("computeAlternatives() error = %v", err)
+	}
+
+	got := map[string]costAlternative{}
+	for _, alternative := range alternatives {
+		got[alternative.Label] = alternative
+	}
+	expected := map[string]string{
+		"Current":          "m7i.xlarge/spot",
+		"arm64 equivalent": "m7g.xlarge/spot",
+		"Other lifecycle":  "m7i.xlarge/on-demand",
+		"Size down":        "m7i.large/spot",
+		"Size up":          "m7i.2xlarge/spot",
+		"Family c7g":       "c7g.xlarge/spot",
+	}
+	if len(got) != len(expected) || alternatives[0].Label != "Current" {
+		t.Fatalf("unexpected alternatives: %+v", alternatives)
+	}
+	for label, instance := range expected {
+		if got[label].InstanceType+"/"+got[label].Lifecycle != instance {
+			t.Errorf("%s = %s/%s, want %s", label, got[label].InstanceType, got[label].Lifecycle, instance)
+		}
+	}
+	// One hour on the current instance costs its hourly price
+	spot := dataset.Regions["us-east-1"].Instances["m7i.xlarge"].Spot
+	if math.Abs(alternatives[0].Cost-spot) > 1e-9 {
+		t.Errorf("current cost = %v, want %v", alternatives[0].Cost, spot)
+	}
+
+	table, err := renderAlternatives(alternatives)
+	if err != nil {
+		t.Fatalf("renderAlternatives() error = %v", err)
+	}
+	if !strings.Contains(table, "| Size up          | m7i.2xlarge   | spot      | x64   | 8     | 32 GiB | $0.1613 | +100.0%    |") {
+		t.Errorf("unexpected table:\n%s", table)
+	}
+}
+
+func TestComputeAlternativesWithoutCurrentPrice(t *testing.T) {
+	dataset := &PricingDataset{Regions: map[string]RegionPricing{
+		"us-east-1": {Instances: map[string]InstancePricing{
+			// No spot price for the current instance
+			"m7i.large":  {Arch: "x64", VCPUs: 2, OnDemand: 0.1},
+			"m7i.xlarge": {Arch: "x64", VCPUs: 4, OnDemand: 0.2, Spot: 0.08},
+		}},
+	}}
+	payload := CostRequestPayload{InstanceType: "m7i.large", InstanceLifecycle: "spot", Region: "us-east-1"}
+	if alternatives, err := dataset.computeAlternatives(payload, 60, nil); err == nil {
+		t.Errorf("computeAlternatives() = %+v, want an error without a price for the current instance", alternatives)
+	}
+
+	// Another alternative is never used as the baseline
+	if table, err := renderAlternatives([]costAlternative{{Label: "Size up", InstanceType: "m7i.xlarge", Lifecycle: "spot", Cost: 0.08}}); err == nil {
+		t.Errorf("renderAlternatives() = %q, want an error without the current instance", table)
+	}
+}
+
+func TestEquivalentArchInstance(t *testing.T) {
+	dataset, err := LoadPricingDataset(t.Context(), "")
+	if err != nil {
+		t.Fatalf("LoadPricingDataset() error = %v", err)
+	}
+	for instanceType, expected := range map[string]string{
+		"m7g.large":      "m7i.large",
+		"c8g.4xlarge":    "c7i.4xlarge",
+		"t3.medium":      "t4g.medium",
+		"m7i-flex.large": "m7g.large",
+	} {
+		instance, _ := dataset.Instance("us-east-1", instanceType)
+		if got, _ := dataset.equivalentArchInstance("us-east-1", instanceType, instance.Arch); got != expected {
+			t.Errorf("equivalentArchInstance(%s) = %s, want %s", instanceType, got, expected)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package costs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/runs-on/action/internal/config"
+	"github.com/runs-on/action/internal/instance"
+	"github.com/sethvargo/go-githubactions"
+)
+
+// ErrCostBudgetExceeded is returned when the job cost exceeds the cost budget, and the budget action is "fail".
+var ErrCostBudgetExceeded = errors.New("job cost exceeded the cost budget")
+
+// checkCostBudget compares the job cost against the cost budget, if any.
+func checkCostBudget(action *githubactions.Action, cfg *config.Config, costData *CostResponseData) error {
+	if !cfg.HasCostBudget() || costData.JobCost() <= cfg.CostBudget {
+		return nil
+	}
+
+	annotated := action.WithFieldsMap(map[string]string{"title": "Cost budget exceeded"})
+	message := fmt.Sprintf("Job cost $%.4f exceeds the cost budget of $%.2f (%s %s, %.0f minutes)",
+		costData.JobCost(), cfg.CostBudget, costData.InstanceLifecycle, costData.InstanceType, costData.DurationMinutes)
+	if cfg.CostBudgetAction == config.CostBudgetActionFail {
+		annotated.Errorf("%s", message)
+		return ErrCostBudgetExceeded
+	}
+	annotated.Warningf("%s", message)
+	return nil
+}
+
+// CheckCostBudgetEarly warns at the start of the job when running until the job timeout would exceed the cost budget,
+// based on the discounted hourly rate of the instance in the pricing dataset.
+func CheckCostBudgetEarly(action *githubactions.Action, cfg *config.Config) {
+	if !cfg.HasCostBudget() {
+		return
+	}
+
+	metadata := instance.Load(context.Background(), imds.New(imds.Options{}))
+	instanceType := metadata.InstanceType
+	lifecycle := metadata.Lifecycle
+	if lifecycle == "" {
+		lifecycle = "spot"
+	}
+
+	if !checkPricingCoverage(action, cfg, metadata.Region) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+	dataset, err := loadPricingDataset(ctx, cfg)
+	if err != nil {
+		action.Infof("Skipping early cost budget check: %v", err)
+		return
+	}
+	hourlyPrice, err := dataset.HourlyPrice(metadata.Region, instanceType, lifecycle, metadata.Az, metadata.ZoneId)
+	if err != nil {
+		action.Infof("Skipping early cost budget check: %v", err)
+		return
+	}
+	checkHourlyPriceBudget(action, cfg, instanceType, lifecycle, hourlyPrice)
+}
+
+// checkHourlyPriceBudget warns when running until the job timeout at the given list hourly price would exceed the cost budget.
+// Discounts apply as in the post-execution budget check, so that both checks agree.
+func checkHourlyPriceBudget(action *githubactions.Action, cfg *config.Config, instanceType, lifecycle string, hourlyPrice float64) {
+	hourlyPrice *= loadDiscounts(action, cfg).Factor(instanceType, lifecycle)
+	maxCost := hourlyPrice * float64(cfg.CostBudgetTimeoutMinutes) / 60
+	if maxCost <= cfg.CostBudget {
+		action.Infof("Cost budget of $%.2f allows the job to run for its full timeout of %d minutes (up to $%.4f at $%.4f/hour)",
+			cfg.CostBudget, cfg.CostBudgetTimeoutMinutes, maxCost, hourlyPrice)
+		return
+	}
+
+	budgetMinutes := cfg.CostBudget / hourlyPrice * 60
+	action.WithFieldsMap(map[string]string{"title": "Cost budget"}).Warningf(
+		"Job could cost up to $%.4f if it runs for its full timeout of %d minutes (%s %s at $%.4f/hour), above the cost budget of $%.2f. The budget will be exceeded after %.0f minutes.",
+		maxCost, cfg.CostBudgetTimeoutMinutes, lifecycle, instanceType, hourlyPrice, cfg.CostBudget, budgetMinutes)
+}
@@ -0,0 +1,63 @@
+package costs
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/runs-on/action/internal/config"
+	"github.com/sethvargo/go-githubactions"
+)
+
+func TestCheckCostBudget(t *testing.T) {
+	costData := &CostResponseData{InstanceType: "c7i.16xlarge", InstanceLifecycle: "on-demand", DurationMinutes: 360, TotalCost: 12.5}
+
+	tests := []struct {
+		name       string
+		cfg        *config.Config
+		wantErr    error
+		wantOutput string
+	}{
+		{"no budget", &config.Config{CostBudgetAction: config.CostBudgetActionFail}, nil, ""},
+		{"within budget", &config.Config{CostBudget: 20, CostBudgetAction: config.CostBudgetActionFail}, nil, ""},
+		{"warn", &config.Config{CostBudget: 5, CostBudgetAction: config.CostBudgetActionWarn}, nil, "::warning title=Cost budget exceeded::Job cost $12.5000 exceeds the cost budget of $5.00"},
+		{"fail", &config.Config{CostBudget: 5, CostBudgetAction: config.CostBudgetActionFail}, ErrCostBudgetExceeded, "::error title=Cost budget exceeded::Job cost $12.5000 exceeds the cost budget of $5.00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			action := githubactions.New(githubactions.WithWriter(&out))
+			err := checkCostBudget(action, tt.cfg, costData)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("checkCostBudget() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantOutput == "" && out.Len() > 0 || !strings.Contains(out.String(), tt.wantOutput) {
+				t.Errorf("unexpected output %q, want %q", out.String(), tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestCheckHourlyPriceBudget(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         *config.Config
+		wantWarning bool
+	}{
+		// Up to $6 over the 60 minutes timeout
+		{"over budget", &config.Config{CostBudget: 4, CostBudgetTimeoutMinutes: 60}, true},
+		// Up to $3.60 with a 40% discount on on-demand instances
+		{"within budget after discount", &config.Config{CostBudget: 4, CostBudgetTimeoutMinutes: 60, CostDiscounts: "on-demand=40"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			action := githubactions.New(githubactions.WithWriter(&out))
+			checkHourlyPriceBudget(action, tt.cfg, "c7i.16xlarge", "on-demand", 6)
+			if got := strings.Contains(out.String(), "::warning title=Cost budget::"); got != tt.wantWarning {
+				t.Errorf("warning = %t, want %t: %q", got, tt.wantWarning, out.String())
+			}
+		})
+	}
+}
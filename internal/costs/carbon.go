@@ -0,0 +1,121 @@
+package costs
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/runs-on/action/internal/config"
+	"github.com/runs-on/action/internal/monitoring"
+	"github.com/sethvargo/go-githubactions"
+)
+
+// CPU utilisation assumed when it is not collected by the metrics agent.
+const defaultCPUUsage = 50.0
+
+// Embedded power profiles and grid carbon intensities, used to estimate the carbon footprint of jobs.
+//
+//go:embed carbon.json
+var embeddedCarbon []byte
+
+// CarbonDataset holds the coefficients used to estimate the energy use and emissions of an instance.
+type CarbonDataset struct {
+	Source            string                `json:"source"`
+	PUE               float64               `json:"pue"`
+	MemoryWattsPerGiB float64               `json:"memoryWattsPerGiB"`
+	CPU               map[string]CPUProfile `json:"cpu"`
+	// Grid carbon intensity per region, in gCO2e/kWh
+	Regions map[string]float64 `json:"regions"`
+}
+
+// CPUProfile holds the power draw of a vCPU at idle and at full load, per architecture.
+type CPUProfile struct {
+	MinWattsPerVCPU float64 `json:"minWattsPerVCPU"`
+	MaxWattsPerVCPU float64 `json:"maxWattsPerVCPU"`
+}
+
+// CarbonEstimate is the estimated energy use and emissions of a job.
+type CarbonEstimate struct {
+	EnergyKWh       float64 `json:"energyKWh"`
+	CO2eGrams       float64 `json:"co2eGrams"`
+	CPUUsage        float64 `json:"cpuUsage"`
+	CPUUsageSource  string  `json:"cpuUsageSource"`
+	GridIntensity   float64 `json:"gridIntensity"`
+	CoefficientsRef string  `json:"coefficientsRef"`
+}
+
+func loadCarbonDataset() (*CarbonDataset, error) {
+	dataset := &CarbonDataset{}
+	if err := json.Unmarshal(embeddedCarbon, dataset); err != nil {
+		return nil, fmt.Errorf("failed to parse carbon dataset: %w", err)
+	}
+	return dataset, nil
+}
+
+// Estimate estimates the energy use and emissions of an instance running for the given duration at the given CPU usage (in percent):
+// CPU power is interpolated between idle and full load, memory power is proportional to its size, and both are scaled by the data center PUE.
+func (d *CarbonDataset) Estimate(region string, instance InstancePricing, duration time.Duration, cpuUsage float64) (*CarbonEstimate, error) {
+	intensity, ok := d.Regions[region]
+	if !ok {
+		return nil, fmt.Errorf("no grid carbon intensity for region %s", region)
+	}
+	profile, ok := d.CPU[instance.Arch]
+	if !ok {
+		return nil, fmt.Errorf("no power profile for architecture %s", instance.Arch)
+	}
+
+	cpuWatts := float64(instance.VCPUs) * (profile.MinWattsPerVCPU + cpuUsage/100*(profile.MaxWattsPerVCPU-profile.MinWattsPerVCPU))
+	memoryWatts := instance.MemoryGiB * d.MemoryWattsPerGiB
+	energyKWh := (cpuWatts + memoryWatts) * d.PUE * duration.Hours() / 1000
+
+	return &CarbonEstimate{
+		EnergyKWh:       energyKWh,
+		CO2eGrams:       energyKWh * intensity,
+		CPUUsage:        cpuUsage,
+		GridIntensity:   intensity,
+		CoefficientsRef: d.Source,
+	}, nil
+}
+
+// addCarbonEstimate estimates the carbon footprint of the job, using the average CPU usage collected by the metrics agent when available.
+func addCarbonEstimate(action *githubactions.Action, cfg *config.Config, costData *CostResponseData, launchedAt time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+
+	pricing, err := loadPricingDataset(ctx, cfg)
+	if err != nil {
+		action.Warningf("Failed to estimate carbon footprint: %v", err)
+		return
+	}
+	// Only the instance specifications are needed, so any region of the dataset will do
+	instance, err := pricing.InstanceSpecs(costData.InstanceType)
+	if err != nil {
+		action.Warningf("Failed to estimate carbon footprint: %v", err)
+		return
+	}
+
+	cpuUsage, cpuUsageSource := defaultCPUUsage, "assumed"
+	if cfg.HasMetrics() && slices.Contains(cfg.Metrics, "cpu") {
+		if usage, err := monitoring.AverageCPUUsage(action, launchedAt); err != nil {
+			action.Infof("Average CPU usage unavailable, assuming %.0f%%: %v", defaultCPUUsage, err)
+		} else {
+			cpuUsage, cpuUsageSource = usage, "measured"
+		}
+	}
+
+	carbon, err := loadCarbonDataset()
+	if err != nil {
+		action.Warningf("Failed to estimate carbon footprint: %v", err)
+		return
+	}
+	estimate, err := carbon.Estimate(costData.Region, instance, time.Duration(costData.DurationMinutes*float64(time.Minute)), cpuUsage)
+	if err != nil {
+		action.Warningf("Failed to estimate carbon footprint: %v", err)
+		return
+	}
+	estimate.CPUUsageSource = cpuUsageSource
+	costData.Carbon = estimate
+}
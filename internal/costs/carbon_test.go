@@ -0,0 +1,32 @@
+package costs
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCarbonDatasetEstimate(t *testing.T) {
+	dataset, err := loadCarbonDataset()
+	if err != nil {
+		t.Fatalf("loadCarbonDataset() error = %v", err)
+	}
+	dataset.PUE = 1.1
+	dataset.MemoryWattsPerGiB = 0.4
+	dataset.CPU["x64"] = CPUProfile{MinWattsPerVCPU: 1, MaxWattsPerVCPU: 3}
+	dataset.Regions["us-east-1"] = 400
+
+	instance := InstancePricing{Arch: "x64", VCPUs: 4, MemoryGiB: 16}
+	estimate, err := dataset.Estimate("us-east-1", instance, 30*time.Minute, 50)
+	if err != nil {
+		t.Fatalf("Estimate() error = %v", err)
+	}
+	// (4 vCPUs * 2W + 16 GiB * 0.4W) * 1.1 PUE * 0.5h = 7.92 Wh
+	if math.Abs(estimate.EnergyKWh-0.00792) > 1e-9 || math.Abs(estimate.CO2eGrams-3.168) > 1e-9 {
+		t.Errorf("Estimate() = %.5f kWh, %.3f gCO2e, want 0.00792 kWh, 3.168 gCO2e", estimate.EnergyKWh, estimate.CO2eGrams)
+	}
+
+	if _, err := dataset.Estimate("xx-unknown-1", instance, time.Hour, 50); err == nil {
+		t.Errorf("expected an error for an unknown region")
+	}
+}
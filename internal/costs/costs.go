@@ -12,14 +12,17 @@ import (
 	"strings"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
 	"github.com/runs-on/action/internal/config"
+	"github.com/runs-on/action/internal/httpclient"
+	"github.com/runs-on/action/internal/instance"
 	"github.com/runs-on/action/internal/utils"
 	"github.com/sethvargo/go-githubactions"
 )
 
 const costAPIURL = "https://go.runs-on.com/api/costs"
+
+// Timeout of the pricing data lookups. The cost API uses the timeout of the shared HTTP client.
 const apiTimeout = 5 * time.Second
 
 // This file is for cost-related logic.
@@ -49,54 +52,42 @@ type CostResponseData struct {
 	TotalCost         float64 `json:"totalCost"`
 	Github            struct {
 		TotalCost float64 `json:"totalCost"`
+		// Runner is the GitHub-hosted runner compared against, when computed from the bundled rates.
+		Runner string `json:"runner,omitempty"`
 	} `json:"github"`
 	Savings struct {
 		Amount     float64 `json:"amount"`
 		Percentage float64 `json:"percentage"`
 	} `json:"savings"`
+	// Source describes where the cost data comes from: the cost API or the local pricing dataset.
+	Source string `json:"source,omitempty"`
+	// Costs on top of the instance compute cost (TotalCost), computed locally.
+	Storage      *CostComponent `json:"storage,omitempty"`
+	DataTransfer *CostComponent `json:"dataTransfer,omitempty"`
+	// List price of the instance, and discount applied to it (in percent), when discounts are configured.
+	ListCost float64 `json:"listCost,omitempty"`
+	Discount float64 `json:"discount,omitempty"`
+	// Estimated carbon footprint of the job, when enabled.
+	Carbon *CarbonEstimate `json:"carbon,omitempty"`
 }
 
-// getZoneIdFromZoneName maps an availability zone name to its zone ID using AWS API
-func getZoneIdFromZoneName(zoneName, region string) (string, error) {
-	if zoneName == "" || region == "" {
-		return "", fmt.Errorf("zone name and region are required")
-	}
-
-	cfg, err := utils.GetAWSClientFromEC2IMDS(context.Background())
-	if err != nil {
-		return "", fmt.Errorf("failed to load AWS config: %w", err)
-	}
-
-	ec2Client := ec2.NewFromConfig(*cfg)
-
-	input := &ec2.DescribeAvailabilityZonesInput{
-		ZoneNames: []string{zoneName},
-	}
-
-	result, err := ec2Client.DescribeAvailabilityZones(context.Background(), input)
-	if err != nil {
-		return "", fmt.Errorf("failed to describe availability zones: %w", err)
-	}
-
-	if len(result.AvailabilityZones) == 0 {
-		return "", fmt.Errorf("no availability zone found for zone name: %s", zoneName)
-	}
-
-	zoneId := aws.ToString(result.AvailabilityZones[0].ZoneId)
-	if zoneId == "" {
-		return "", fmt.Errorf("zone ID not found for zone name: %s", zoneName)
+// JobCost returns the compute cost of the job, plus its storage and data transfer costs when known.
+func (c *CostResponseData) JobCost() float64 {
+	total := c.TotalCost
+	for _, component := range []*CostComponent{c.Storage, c.DataTransfer} {
+		if component != nil {
+			total += component.Cost
+		}
 	}
-
-	return zoneId, nil
+	return total
 }
 
 // ComputeAndDisplayCosts fetches cost data and displays it based on config.
 func ComputeAndDisplayCosts(action *githubactions.Action, cfg *config.Config) error {
-	// Get the display costs option value (use config value)
+	// Costs are displayed when 'inline' or 'summary', and computed anyway for the cost budget
 	displayCostsOption := cfg.ShowCosts
-
-	// Disable if not 'inline' or 'summary'
-	if displayCostsOption != "inline" && displayCostsOption != "summary" {
+	display := displayCostsOption == "inline" || displayCostsOption == "summary"
+	if !display && !cfg.HasCostBudget() {
 		action.Infof("Cost calculation is disabled (show-costs=%s)", displayCostsOption)
 		return nil
 	}
@@ -107,92 +98,98 @@ func ComputeAndDisplayCosts(action *githubactions.Action, cfg *config.Config) er
 		return nil // Not an error, just can't proceed
 	}
 
-	// Get runner information from environment variables
-	region := os.Getenv("RUNS_ON_AWS_REGION")
-	az := os.Getenv("RUNS_ON_AWS_AZ")
-	instanceType := os.Getenv("RUNS_ON_INSTANCE_TYPE")
-	instanceLifecycle := os.Getenv("RUNS_ON_INSTANCE_LIFECYCLE")
+	// Get runner information from IMDS, falling back to environment variables
+	metadata := instance.Load(context.Background(), imds.New(imds.Options{}))
+	instanceLifecycle := metadata.Lifecycle
 	if instanceLifecycle == "" {
 		instanceLifecycle = "spot" // Default to spot if not provided
 	}
-	instanceArchitecture := os.Getenv("RUNS_ON_AGENT_ARCH")
+	instanceArchitecture := metadata.Arch
 	if instanceArchitecture == "" {
 		instanceArchitecture = "x64" // Default to x64 if not provided
 	}
+	if metadata.ZoneId == "" {
+		action.Infof("Zone ID not available from IMDS for zone %s. Using zone name instead, report might not be completely accurate.", metadata.Az)
+	}
 
 	platform := runtime.GOOS
 
-	// Attempt to find the zone ID mapping for the zone name
-	zoneId := "" // Default to empty string if mapping fails
-	if az != "" && region != "" {
-		if mappedZoneId, err := getZoneIdFromZoneName(az, region); err != nil {
-			// FIXME: change to warning 2 months after v2.8.4 release of RunsOn
-			action.Infof("Failed to get zone ID for zone %s: %v. Using zone name instead, report might not be completely accurate.", az, err)
-		} else {
-			zoneId = mappedZoneId
-			action.Infof("Mapped zone name %s to zone ID %s", az, zoneId)
-		}
-	}
-
 	// Prepare request payload
 	payload := CostRequestPayload{
-		InstanceType:      instanceType,
+		InstanceType:      metadata.InstanceType,
 		InstanceLifecycle: instanceLifecycle,
-		Region:            region,
-		Az:                az,
-		ZoneId:            zoneId, // Use zone ID instead of zone name
+		Region:            metadata.Region,
+		Az:                metadata.Az,
+		ZoneId:            metadata.ZoneId, // Use zone ID instead of zone name
 		Arch:              instanceArchitecture,
 		StartedAt:         instanceLaunchedAt,
 		Platform:          platform,
 	}
 
-	payloadBytes, err := json.Marshal(payload)
+	costData, err := fetchCostData(httpclient.New(action, cfg.HTTPTimeout), payload)
 	if err != nil {
-		return fmt.Errorf("failed to marshal cost request payload: %w", err)
+		action.Warningf("Cost API unavailable, computing costs from the local pricing dataset instead: %v", err)
+		costData, err = computeLocalCostData(cfg, payload)
+		if err != nil {
+			return fmt.Errorf("failed to compute costs from the local pricing dataset: %w", err)
+		}
 	}
 
-	// Make API request with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, costAPIURL, bytes.NewReader(payloadBytes))
-	if err != nil {
-		return fmt.Errorf("failed to create cost API request: %w", err)
+	// Storage and data transfer costs and the what-if comparison are computed from the pricing dataset
+	pricingCovered := (cfg.StorageCosts || cfg.DataTransfer != "none" || cfg.CostAlternatives) && checkPricingCoverage(action, cfg, payload.Region)
+	if pricingCovered {
+		addCostComponents(action, cfg, costData)
 	}
-	req.Header.Set("Content-Type", "application/json")
-	// Consider adding a User-Agent header
+	applyGithubRunner(action, cfg, costData)
+	discounts := loadDiscounts(action, cfg)
+	applyDiscounts(costData, discounts)
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		// Check for context deadline exceeded
-		if ctx.Err() == context.DeadlineExceeded {
-			return fmt.Errorf("cost API request timed out after %s", apiTimeout)
+	if cfg.ShowCarbon {
+		if launchedAt, err := time.Parse(time.RFC3339, instanceLaunchedAt); err != nil {
+			action.Warningf("Failed to parse RUNS_ON_INSTANCE_LAUNCHED_AT '%s': %v", instanceLaunchedAt, err)
+		} else {
+			addCarbonEstimate(action, cfg, costData, launchedAt)
 		}
-		return fmt.Errorf("failed to send cost API request: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		// Read body for more details if possible
-		bodyBytes, readErr := io.ReadAll(resp.Body)
-		if readErr != nil {
-			return fmt.Errorf("cost API request failed with status %s (failed to read body: %v)", resp.Status, readErr)
+	setCostOutputs(action, costData)
+	if err := writeCostReport(cfg.CostReport, costData); err != nil {
+		action.Warningf("Failed to write cost report: %v", err)
+	} else {
+		action.SetOutput("cost_report", cfg.CostReport)
+		action.Infof("Cost report written to %s", cfg.CostReport)
+	}
+
+	// Save the job cost for the workflow run cost rollup
+	if os.Getenv("RUNS_ON_S3_BUCKET_CACHE") != "" {
+		if location, err := saveCostRecord(cfg, costData); err != nil {
+			action.Warningf("Failed to save cost record for the workflow run cost rollup: %v", err)
+		} else {
+			action.Infof("Cost record saved to %s", location)
 		}
-		return fmt.Errorf("cost API request failed with status %s: %s", resp.Status, string(bodyBytes))
 	}
 
-	// Decode response
-	var costData CostResponseData
-	if err := json.NewDecoder(resp.Body).Decode(&costData); err != nil {
-		return fmt.Errorf("failed to decode cost API response: %w", err)
+	if display {
+		displayCosts(action, cfg, payload, costData, discounts, pricingCovered, instanceLaunchedAt)
 	}
 
+	return checkCostBudget(action, cfg, costData)
+}
+
+// displayCosts displays the cost summary, along with the what-if and GitHub comparisons and the timeline when enabled,
+// in the log and, for 'summary', in the job summary.
+func displayCosts(action *githubactions.Action, cfg *config.Config, payload CostRequestPayload, costData *CostResponseData, discounts Discounts, pricingCovered bool, instanceLaunchedAt string) {
 	// Generate formatted data strings once
 	durationStr := fmt.Sprintf("%.2f minutes", costData.DurationMinutes)
 	costStr := fmt.Sprintf("$%.4f", costData.TotalCost)
 	githubCostStr := fmt.Sprintf("$%.4f", costData.Github.TotalCost)
 	savingsStr := fmt.Sprintf("$%.4f (%.1f%%)", costData.Savings.Amount, costData.Savings.Percentage)
+	if costData.Github.Runner != "" {
+		githubCostStr = fmt.Sprintf("%s (%s)", githubCostStr, costData.Github.Runner)
+	}
+	if costData.Github.TotalCost == 0 {
+		githubCostStr, savingsStr = "n/a", "n/a"
+	}
 
 	headers := []string{"metric", "value"}
 	rows := [][]string{
@@ -207,59 +204,168 @@ func ComputeAndDisplayCosts(action *githubactions.Action, cfg *config.Config) er
 		{"Cost", costStr},
 		{"GitHub equivalent cost", githubCostStr},
 		{"Savings", savingsStr},
+		{"Pricing source", costData.Source},
 	}
-	markdownTableString := renderMarkdownTable(headers, rows)
+	if costData.Storage != nil || costData.DataTransfer != nil {
+		costRows := [][]string{{"Cost", costStr}}
+		if costData.Storage != nil {
+			costRows = append(costRows, []string{fmt.Sprintf("Storage cost (%s)", costData.Storage.Description), fmt.Sprintf("$%.4f", costData.Storage.Cost)})
+		}
+		if costData.DataTransfer != nil {
+			costRows = append(costRows, []string{fmt.Sprintf("Data transfer cost (%s)", costData.DataTransfer.Description), fmt.Sprintf("$%.4f", costData.DataTransfer.Cost)})
+		}
+		costRows = append(costRows, []string{"Total cost", fmt.Sprintf("$%.4f", costData.JobCost())})
+		rows = insertRows(rows, "Cost", costRows)
+	}
+	if costData.ListCost > 0 {
+		rows = insertRows(rows, "Cost", [][]string{
+			{"Cost", fmt.Sprintf("%s (%.1f%% discount)", costStr, costData.Discount)},
+			{"List price cost", fmt.Sprintf("$%.4f", costData.ListCost)},
+		})
+	}
+	if costData.Carbon != nil {
+		rows = append(rows,
+			[]string{"Energy (estimated)", fmt.Sprintf("%.4f kWh (CPU %.0f%% %s)", costData.Carbon.EnergyKWh, costData.Carbon.CPUUsage, costData.Carbon.CPUUsageSource)},
+			[]string{"Carbon footprint (estimated)", fmt.Sprintf("%.2f gCO2e (%.0f gCO2e/kWh)", costData.Carbon.CO2eGrams, costData.Carbon.GridIntensity)},
+		)
+	}
+	markdownTableString := utils.RenderMarkdownTable(headers, rows)
 
 	summaryBuilder := &strings.Builder{}
 	summaryBuilder.WriteString("## Execution Cost Summary\n\n")
 	summaryBuilder.WriteString(markdownTableString)
 	summaryBuilder.WriteString("\n") // Add a newline for spacing
 
+	if cfg.CostAlternatives && pricingCovered {
+		if alternativesTable, err := computeAlternativesTable(cfg, payload, costData.DurationMinutes, discounts); err != nil {
+			action.Infof("Skipping what-if cost comparison: %v", err)
+		} else {
+			summaryBuilder.WriteString(alternativesTable)
+			summaryBuilder.WriteString("\n")
+		}
+	}
+
+	if len(cfg.GithubRunners) > 0 {
+		if githubTable, err := computeGithubTable(cfg, costData); err != nil {
+			action.Warningf("Failed to compare against GitHub-hosted runners: %v", err)
+		} else {
+			summaryBuilder.WriteString(githubTable)
+			summaryBuilder.WriteString("\n")
+		}
+	}
+
 	fmt.Print(summaryBuilder.String())
 
-	if displayCostsOption == "summary" {
+	if cfg.ShowCosts == "summary" {
 		action.AddStepSummary(summaryBuilder.String())
 		action.Infof("Cost summary added to job summary.")
 	}
 
-	return nil
+	if cfg.ShowTimeline {
+		displayTimeline(action, instanceLaunchedAt, costData)
+	}
 }
 
-// not using a proper markdown library (yet)
-func renderMarkdownTable(headers []string, rows [][]string) string {
-	// Find max width for each column
-	colWidths := make([]int, len(headers))
-	for i, h := range headers {
-		colWidths[i] = len(h)
-	}
-	for _, row := range rows {
-		for i, cell := range row {
-			if len(cell) > colWidths[i] {
-				colWidths[i] = len(cell)
-			}
-		}
+// computeAlternativesTable renders what the job duration would cost on other instances, from the pricing dataset.
+// Discounts apply to the alternatives too, so that they compare with the job cost.
+func computeAlternativesTable(cfg *config.Config, payload CostRequestPayload, durationMinutes float64, discounts Discounts) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+
+	dataset, err := loadPricingDataset(ctx, cfg)
+	if err != nil {
+		return "", err
+	}
+	alternatives, err := dataset.computeAlternatives(payload, durationMinutes, cfg.CostAlternativeFamilies)
+	if err != nil {
+		return "", err
+	}
+	for i := range alternatives {
+		alternatives[i].Cost *= discounts.Factor(alternatives[i].InstanceType, alternatives[i].Lifecycle)
+	}
+	return renderAlternatives(alternatives)
+}
+
+// displayTimeline displays the step timeline of the job, with the cost of each step, in the log and the job summary.
+func displayTimeline(action *githubactions.Action, instanceLaunchedAt string, costData *CostResponseData) {
+	launchedAt, err := time.Parse(time.RFC3339, instanceLaunchedAt)
+	if err != nil {
+		action.Warningf("Failed to parse RUNS_ON_INSTANCE_LAUNCHED_AT '%s': %v", instanceLaunchedAt, err)
+		return
+	}
+	steps, err := loadStepTimeline(launchedAt, time.Now())
+	if err != nil {
+		action.Warningf("Failed to load the job step timeline: %v", err)
+		return
+	}
+	if len(steps) == 0 {
+		action.Infof("No steps found in the runner diagnostic logs, skipping job timeline.")
+		return
+	}
+
+	timeline := renderTimeline(steps, costData)
+	fmt.Print(timeline)
+	action.AddStepSummary(timeline)
+	action.Infof("Job timeline added to job summary.")
+}
+
+// fetchCostData requests the cost of the instance described by the payload from the cost API.
+func fetchCostData(client *httpclient.Client, payload CostRequestPayload) (*CostResponseData, error) {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal cost request payload: %w", err)
 	}
 
-	// Helper to pad a row
-	padRow := func(row []string) string {
-		out := "|"
-		for i, cell := range row {
-			out += " " + cell + strings.Repeat(" ", colWidths[i]-len(cell)) + " |"
+	req, err := http.NewRequest(http.MethodPost, costAPIURL, bytes.NewReader(payloadBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cost API request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send cost API request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		// Read body for more details if possible
+		bodyBytes, readErr := io.ReadAll(resp.Body)
+		if readErr != nil {
+			return nil, fmt.Errorf("cost API request failed with status %s (failed to read body: %v)", resp.Status, readErr)
 		}
-		return out
+		return nil, fmt.Errorf("cost API request failed with status %s: %s", resp.Status, string(bodyBytes))
 	}
 
-	// Build separator
-	sep := "|"
-	for _, w := range colWidths {
-		sep += " " + strings.Repeat("-", w) + " |"
+	// Decode response
+	var costData CostResponseData
+	if err := json.NewDecoder(resp.Body).Decode(&costData); err != nil {
+		return nil, fmt.Errorf("failed to decode cost API response: %w", err)
 	}
+	costData.Source = "cost API"
+
+	return &costData, nil
+}
+
+// computeLocalCostData computes the cost of the instance described by the payload from the configured pricing dataset.
+func computeLocalCostData(cfg *config.Config, payload CostRequestPayload) (*CostResponseData, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
 
-	var b strings.Builder
-	b.WriteString(padRow(headers) + "\n")
-	b.WriteString(sep + "\n")
-	for _, row := range rows {
-		b.WriteString(padRow(row) + "\n")
+	dataset, err := loadPricingDataset(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return dataset.ComputeCost(payload, time.Now())
+}
+
+// insertRows replaces the row with the given label by the given rows.
+func insertRows(rows [][]string, label string, replacement [][]string) [][]string {
+	for i, row := range rows {
+		if row[0] == label {
+			return append(rows[:i], append(replacement, rows[i+1:]...)...)
+		}
 	}
-	return b.String()
+	return append(rows, replacement...)
 }
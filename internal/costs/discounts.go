@@ -0,0 +1,138 @@
+package costs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/runs-on/action/internal/config"
+	"github.com/runs-on/action/internal/utils"
+	"github.com/sethvargo/go-githubactions"
+)
+
+// discountAll is the discount key applied on top of every other discount, e.g. an EDP discount.
+const discountAll = "all"
+
+// Discounts holds discount percentages by instance lifecycle ("spot", "on-demand"), by instance family (e.g. "m7g"),
+// or for everything ("all").
+type Discounts map[string]float64
+
+// ParseDiscounts parses discounts given as a comma-separated list of key=percentage, e.g. "on-demand=28,m7g=35,all=5".
+func ParseDiscounts(input string) (Discounts, error) {
+	discounts := Discounts{}
+	for _, entry := range strings.Split(strings.ReplaceAll(input, " ", ""), ",") {
+		if entry == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid discount %q, expected key=percentage", entry)
+		}
+		percentage, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		if err != nil || percentage < 0 || percentage > 100 {
+			return nil, fmt.Errorf("invalid discount percentage %q for %s", value, key)
+		}
+		discounts[key] = percentage
+	}
+	return discounts, nil
+}
+
+// LoadDiscountsFile loads discounts from a JSON object of key to percentage, in an s3://bucket/key URL or a local file.
+func LoadDiscountsFile(ctx context.Context, location string, optFns ...func(*s3.Options)) (Discounts, error) {
+	var raw []byte
+	if strings.HasPrefix(location, "s3://") {
+		bucket, key, err := utils.ParseS3URL(location)
+		if err != nil {
+			return nil, err
+		}
+		client, err := utils.GetS3ClientFromEC2IMDS(ctx, optFns...)
+		if err != nil {
+			return nil, err
+		}
+		object, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+		if err != nil {
+			return nil, fmt.Errorf("failed to download discounts file from %s: %w", location, err)
+		}
+		defer object.Body.Close()
+		if raw, err = io.ReadAll(object.Body); err != nil {
+			return nil, fmt.Errorf("failed to read discounts file from %s: %w", location, err)
+		}
+	} else {
+		var err error
+		if raw, err = os.ReadFile(location); err != nil {
+			return nil, fmt.Errorf("failed to read discounts file: %w", err)
+		}
+	}
+
+	discounts := Discounts{}
+	if err := json.Unmarshal(raw, &discounts); err != nil {
+		return nil, fmt.Errorf("failed to parse discounts file: %w", err)
+	}
+	return discounts, nil
+}
+
+// loadDiscounts loads the discounts configured for the action. Discounts given as input override the ones from the discounts file.
+func loadDiscounts(action *githubactions.Action, cfg *config.Config) Discounts {
+	discounts := Discounts{}
+	if cfg.CostDiscountsFile != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+		defer cancel()
+		fileDiscounts, err := LoadDiscountsFile(ctx, cfg.CostDiscountsFile, utils.S3EndpointOptions(cfg.S3Endpoint, cfg.S3PathStyle))
+		if err != nil {
+			action.Warningf("Failed to load cost discounts file, ignoring it: %v", err)
+		}
+		for key, percentage := range fileDiscounts {
+			discounts[key] = percentage
+		}
+	}
+	if cfg.CostDiscounts != "" {
+		inputDiscounts, err := ParseDiscounts(cfg.CostDiscounts)
+		if err != nil {
+			action.Warningf("Error parsing 'cost_discounts' input '%s': %v. Ignoring it.", cfg.CostDiscounts, err)
+		}
+		for key, percentage := range inputDiscounts {
+			discounts[key] = percentage
+		}
+	}
+	return discounts
+}
+
+// Factor returns the share of the list price paid for an instance type and lifecycle.
+// The instance family discount takes precedence over the lifecycle discount, and the "all" discount applies on top of them.
+func (d Discounts) Factor(instanceType, lifecycle string) float64 {
+	family, _ := splitInstanceType(instanceType)
+	specific, ok := d[family]
+	if !ok {
+		specific = d[lifecycle]
+	}
+	return (1 - specific/100) * (1 - d[discountAll]/100)
+}
+
+// applyDiscounts applies the discounts to the job cost, and recomputes the savings against GitHub.
+// The list price of the instance is kept in ListCost. Storage and data transfer costs only get the "all" discount.
+func applyDiscounts(costData *CostResponseData, discounts Discounts) {
+	factor := discounts.Factor(costData.InstanceType, costData.InstanceLifecycle)
+	if factor == 1 {
+		return
+	}
+
+	costData.ListCost = costData.TotalCost
+	costData.Discount = (1 - factor) * 100
+	costData.TotalCost *= factor
+	for _, component := range []*CostComponent{costData.Storage, costData.DataTransfer} {
+		if component != nil {
+			component.Cost *= 1 - discounts[discountAll]/100
+		}
+	}
+
+	if costData.Github.TotalCost > 0 {
+		costData.Savings.Amount = costData.Github.TotalCost - costData.TotalCost
+		costData.Savings.Percentage = costData.Savings.Amount / costData.Github.TotalCost * 100
+	}
+}
@@ -0,0 +1,62 @@
+package costs
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseDiscounts(t *testing.T) {
+	discounts, err := ParseDiscounts("on-demand=28, m7g=35%,all=5")
+	if err != nil {
+		t.Fatalf("ParseDiscounts() error = %v", err)
+	}
+	if len(discounts) != 3 || discounts["on-demand"] != 28 || discounts["m7g"] != 35 || discounts["all"] != 5 {
+		t.Errorf("unexpected discounts: %v", discounts)
+	}
+
+	for _, invalid := range []string{"on-demand", "spot=abc", "all=120"} {
+		if _, err := ParseDiscounts(invalid); err == nil {
+			t.Errorf("ParseDiscounts(%q) expected an error", invalid)
+		}
+	}
+}
+
+func TestDiscountsFactor(t *testing.T) {
+	discounts := Discounts{"on-demand": 28, "m7g": 35, "all": 5}
+	tests := []struct {
+		instanceType, lifecycle string
+		want                    float64
+	}{
+		{"m7i.large", "on-demand", 0.72 * 0.95},
+		{"m7g.large", "on-demand", 0.65 * 0.95},
+		{"m7i.large", "spot", 0.95},
+	}
+	for _, tt := range tests {
+		if got := discounts.Factor(tt.instanceType, tt.lifecycle); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Factor(%s, %s) = %v, want %v", tt.instanceType, tt.lifecycle, got, tt.want)
+		}
+	}
+}
+
+func TestApplyDiscounts(t *testing.T) {
+	costData := &CostResponseData{InstanceType: "m7i.large", InstanceLifecycle: "on-demand", TotalCost: 1, Storage: &CostComponent{Cost: 0.1}}
+	costData.Github.TotalCost = 2
+
+	applyDiscounts(costData, Discounts{"on-demand": 20, "all": 50})
+
+	if math.Abs(costData.TotalCost-0.4) > 1e-9 || costData.ListCost != 1 || math.Abs(costData.Discount-60) > 1e-9 {
+		t.Errorf("TotalCost = %v, ListCost = %v, Discount = %v, want 0.4, 1 and 60", costData.TotalCost, costData.ListCost, costData.Discount)
+	}
+	if math.Abs(costData.Storage.Cost-0.05) > 1e-9 {
+		t.Errorf("Storage cost = %v, want 0.05", costData.Storage.Cost)
+	}
+	if math.Abs(costData.Savings.Amount-1.6) > 1e-9 || math.Abs(costData.Savings.Percentage-80) > 1e-9 {
+		t.Errorf("Savings = %+v, want 1.6 (80%%)", costData.Savings)
+	}
+
+	undiscounted := &CostResponseData{InstanceType: "m7i.large", InstanceLifecycle: "spot", TotalCost: 1}
+	applyDiscounts(undiscounted, Discounts{"on-demand": 20})
+	if undiscounted.TotalCost != 1 || undiscounted.ListCost != 0 {
+		t.Errorf("expected no discount for spot, got %+v", undiscounted)
+	}
+}
@@ -0,0 +1,244 @@
+package costs
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/runs-on/action/internal/config"
+	"github.com/runs-on/action/internal/utils"
+	"github.com/sethvargo/go-githubactions"
+)
+
+// Embedded per-minute rates of GitHub-hosted runners, used to compare the job cost against GitHub without the cost API.
+//
+//go:embed github_rates.json
+var embeddedGithubRates []byte
+
+// GithubRates holds the per-minute rates of GitHub-hosted runners, by runner SKU.
+type GithubRates struct {
+	Source    string                `json:"source"`
+	UpdatedAt string                `json:"updatedAt"`
+	Runners   map[string]GithubRate `json:"runners"`
+}
+
+// GithubRate holds the characteristics and per-minute rate (USD) of a GitHub-hosted runner.
+// Aliases are other names of the runner, e.g. runner labels such as "ubuntu-latest".
+type GithubRate struct {
+	OS        string   `json:"os"`
+	Arch      string   `json:"arch"`
+	VCPUs     int      `json:"vcpus"`
+	PerMinute float64  `json:"perMinute"`
+	Aliases   []string `json:"aliases,omitempty"`
+}
+
+// githubComparison is the cost of the job duration on a GitHub-hosted runner.
+type githubComparison struct {
+	Runner        string
+	PerMinute     float64
+	BilledMinutes float64
+	Cost          float64
+}
+
+// LoadGithubRates loads the embedded GitHub rates, extended or overridden by the runners of the given rates file
+// (an s3://bucket/key URL or a local file path), if any.
+func LoadGithubRates(ctx context.Context, location string, optFns ...func(*s3.Options)) (*GithubRates, error) {
+	rates := &GithubRates{}
+	if err := json.Unmarshal(embeddedGithubRates, rates); err != nil {
+		return nil, fmt.Errorf("failed to parse embedded GitHub rates: %w", err)
+	}
+	if location == "" {
+		return rates, nil
+	}
+
+	var raw []byte
+	if strings.HasPrefix(location, "s3://") {
+		bucket, key, err := utils.ParseS3URL(location)
+		if err != nil {
+			return nil, err
+		}
+		client, err := utils.GetS3ClientFromEC2IMDS(ctx, optFns...)
+		if err != nil {
+			return nil, err
+		}
+		object, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+		if err != nil {
+			return nil, fmt.Errorf("failed to download GitHub rates from %s: %w", location, err)
+		}
+		defer object.Body.Close()
+		if raw, err = io.ReadAll(object.Body); err != nil {
+			return nil, fmt.Errorf("failed to read GitHub rates from %s: %w", location, err)
+		}
+	} else {
+		var err error
+		if raw, err = os.ReadFile(location); err != nil {
+			return nil, fmt.Errorf("failed to read GitHub rates: %w", err)
+		}
+	}
+
+	custom := &GithubRates{}
+	if err := json.Unmarshal(raw, custom); err != nil {
+		return nil, fmt.Errorf("failed to parse GitHub rates: %w", err)
+	}
+	for runner, rate := range custom.Runners {
+		rates.Runners[runner] = rate
+	}
+	rates.Source = location
+	if custom.Source != "" {
+		rates.Source = custom.Source
+	}
+	return rates, nil
+}
+
+// Lookup returns the canonical name and rate of a runner, by name or alias.
+func (r *GithubRates) Lookup(runner string) (string, GithubRate, error) {
+	if rate, ok := r.Runners[runner]; ok {
+		return runner, rate, nil
+	}
+	for name, rate := range r.Runners {
+		for _, alias := range rate.Aliases {
+			if alias == runner {
+				return name, rate, nil
+			}
+		}
+	}
+	return "", GithubRate{}, fmt.Errorf("no GitHub rate for runner %s", runner)
+}
+
+// Equivalent returns the runner matching the platform and architecture with the closest number of vCPUs, preferring larger runners.
+func (r *GithubRates) Equivalent(platform, arch string, vcpus int) (string, bool) {
+	best, bestDistance := "", math.MaxInt
+	names := make([]string, 0, len(r.Runners))
+	for name := range r.Runners {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		rate := r.Runners[name]
+		if rate.OS != platform || rate.Arch != arch {
+			continue
+		}
+		distance := 2 * (rate.VCPUs - vcpus)
+		if distance < 0 {
+			distance = -distance + 1
+		}
+		if distance < bestDistance {
+			best, bestDistance = name, distance
+		}
+	}
+	return best, best != ""
+}
+
+// Compare returns the cost of the given duration on a runner. GitHub bills each job by the minute, rounded up.
+func (r *GithubRates) Compare(runner string, durationMinutes float64) (githubComparison, error) {
+	name, rate, err := r.Lookup(runner)
+	if err != nil {
+		return githubComparison{}, err
+	}
+	billedMinutes := math.Ceil(durationMinutes)
+	if name != runner {
+		name = fmt.Sprintf("%s (%s)", runner, name)
+	}
+	return githubComparison{Runner: name, PerMinute: rate.PerMinute, BilledMinutes: billedMinutes, Cost: rate.PerMinute * billedMinutes}, nil
+}
+
+// githubRatesCache memoizes loaded GitHub rates by location.
+var githubRatesCache = map[string]*GithubRates{}
+
+func loadGithubRates(ctx context.Context, cfg *config.Config) (*GithubRates, error) {
+	if rates, ok := githubRatesCache[cfg.GithubRatesFile]; ok {
+		return rates, nil
+	}
+	rates, err := LoadGithubRates(ctx, cfg.GithubRatesFile, utils.S3EndpointOptions(cfg.S3Endpoint, cfg.S3PathStyle))
+	if err != nil {
+		return nil, err
+	}
+	githubRatesCache[cfg.GithubRatesFile] = rates
+	return rates, nil
+}
+
+// applyGithubRunner computes the GitHub equivalent cost from the bundled rates, against the configured runner.
+// Without a configured runner, the equivalent runner is picked from the instance vCPUs when the cost API did not provide a GitHub cost.
+func applyGithubRunner(action *githubactions.Action, cfg *config.Config, costData *CostResponseData) {
+	if cfg.GithubRunner == "" && costData.Github.TotalCost > 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+	rates, err := loadGithubRates(ctx, cfg)
+	if err != nil {
+		action.Warningf("Failed to load GitHub rates: %v", err)
+		return
+	}
+
+	runner := cfg.GithubRunner
+	if runner == "" {
+		pricing, err := loadPricingDataset(ctx, cfg)
+		if err != nil {
+			return
+		}
+		instance, err := pricing.InstanceSpecs(costData.InstanceType)
+		if err != nil {
+			return
+		}
+		var ok bool
+		if runner, ok = rates.Equivalent(costData.Platform, instance.Arch, instance.VCPUs); !ok {
+			return
+		}
+	}
+
+	comparison, err := rates.Compare(runner, costData.DurationMinutes)
+	if err != nil {
+		action.Warningf("Failed to compare against GitHub runner: %v", err)
+		return
+	}
+	costData.Github.TotalCost = comparison.Cost
+	costData.Github.Runner = comparison.Runner
+	costData.Savings.Amount = costData.Github.TotalCost - costData.TotalCost
+	costData.Savings.Percentage = costData.Savings.Amount / costData.Github.TotalCost * 100
+}
+
+// computeGithubTable renders what the job duration would cost on each of the configured GitHub runners.
+func computeGithubTable(cfg *config.Config, costData *CostResponseData) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+	rates, err := loadGithubRates(ctx, cfg)
+	if err != nil {
+		return "", err
+	}
+
+	rows := [][]string{}
+	for _, runner := range cfg.GithubRunners {
+		comparison, err := rates.Compare(runner, costData.DurationMinutes)
+		if err != nil {
+			rows = append(rows, []string{runner, "n/a", "n/a", "n/a", "n/a"})
+			continue
+		}
+		savings := "n/a"
+		if comparison.Cost > 0 {
+			savings = fmt.Sprintf("$%.4f (%.1f%%)", comparison.Cost-costData.TotalCost, (comparison.Cost-costData.TotalCost)/comparison.Cost*100)
+		}
+		rows = append(rows, []string{
+			comparison.Runner,
+			fmt.Sprintf("$%.3f", comparison.PerMinute),
+			fmt.Sprintf("%.0f", comparison.BilledMinutes),
+			fmt.Sprintf("$%.4f", comparison.Cost),
+			savings,
+		})
+	}
+
+	b := &strings.Builder{}
+	b.WriteString("### GitHub-hosted runners comparison\n\n")
+	b.WriteString(utils.RenderMarkdownTable([]string{"runner", "rate per minute", "billed minutes", "cost", "savings"}, rows))
+	fmt.Fprintf(b, "\nRates: %s\n", rates.Source)
+	return b.String(), nil
+}
@@ -0,0 +1,67 @@
+package costs
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGithubRatesCompare(t *testing.T) {
+	rates, err := LoadGithubRates(t.Context(), "")
+	if err != nil {
+		t.Fatalf("LoadGithubRates() error = %v", err)
+	}
+
+	comparison, err := rates.Compare("ubuntu-latest-8-cores", 10.2)
+	if err != nil {
+		t.Fatalf("Compare() error = %v", err)
+	}
+	// Billed by the minute, rounded up
+	if comparison.Runner != "ubuntu-latest-8-cores (linux-8-core)" || comparison.BilledMinutes != 11 || math.Abs(comparison.Cost-11*comparison.PerMinute) > 1e-9 {
+		t.Errorf("unexpected comparison: %+v", comparison)
+	}
+
+	if _, err := rates.Compare("unknown-runner", 1); err == nil {
+		t.Errorf("expected an error for an unknown runner")
+	}
+}
+
+func TestGithubRatesEquivalent(t *testing.T) {
+	rates, err := LoadGithubRates(t.Context(), "")
+	if err != nil {
+		t.Fatalf("LoadGithubRates() error = %v", err)
+	}
+	tests := []struct {
+		platform, arch string
+		vcpus          int
+		want           string
+	}{
+		{"linux", "x64", 2, "linux-2-core"},
+		{"linux", "x64", 12, "linux-16-core"},
+		{"linux", "arm64", 4, "linux-arm64-4-core"},
+		{"linux", "x64", 192, "linux-96-core"},
+	}
+	for _, tt := range tests {
+		if got, _ := rates.Equivalent(tt.platform, tt.arch, tt.vcpus); got != tt.want {
+			t.Errorf("Equivalent(%s, %s, %d) = %s, want %s", tt.platform, tt.arch, tt.vcpus, got, tt.want)
+		}
+	}
+}
+
+func TestLoadGithubRatesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	if err := os.WriteFile(path, []byte(`{"runners": {"linux-8-core": {"os": "linux", "arch": "x64", "vcpus": 8, "perMinute": 0.1}, "gpu": {"os": "linux", "arch": "x64", "vcpus": 4, "perMinute": 0.07}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rates, err := LoadGithubRates(t.Context(), path)
+	if err != nil {
+		t.Fatalf("LoadGithubRates() error = %v", err)
+	}
+	if rates.Runners["linux-8-core"].PerMinute != 0.1 || rates.Runners["gpu"].PerMinute != 0.07 || rates.Runners["linux-2-core"].PerMinute == 0 {
+		t.Errorf("expected custom rates to extend and override the bundled ones, got %+v", rates.Runners)
+	}
+	if rates.Source != path {
+		t.Errorf("Source = %s, want %s", rates.Source, path)
+	}
+}
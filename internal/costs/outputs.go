@@ -0,0 +1,39 @@
+package costs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sethvargo/go-githubactions"
+)
+
+// setCostOutputs exposes the cost data as step outputs.
+// Outputs set in the post-execution step are not visible to other steps, hence the "costs" mode.
+func setCostOutputs(action *githubactions.Action, costData *CostResponseData) {
+	action.SetOutput("cost", fmt.Sprintf("%.4f", costData.TotalCost))
+	action.SetOutput("list_cost", fmt.Sprintf("%.4f", costData.ListCost))
+	action.SetOutput("total_cost", fmt.Sprintf("%.4f", costData.JobCost()))
+	action.SetOutput("github_equivalent_cost", fmt.Sprintf("%.4f", costData.Github.TotalCost))
+	action.SetOutput("savings", fmt.Sprintf("%.4f", costData.Savings.Amount))
+	action.SetOutput("duration_minutes", fmt.Sprintf("%.2f", costData.DurationMinutes))
+	action.SetOutput("instance_type", costData.InstanceType)
+	action.SetOutput("lifecycle", costData.InstanceLifecycle)
+}
+
+// writeCostReport writes the cost data as a JSON file at the given path.
+func writeCostReport(path string, costData *CostResponseData) error {
+	report, err := json.MarshalIndent(costData, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal cost report: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create cost report directory: %w", err)
+	}
+	if err := os.WriteFile(path, report, 0644); err != nil {
+		return fmt.Errorf("failed to write cost report: %w", err)
+	}
+	return nil
+}
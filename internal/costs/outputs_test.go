@@ -0,0 +1,99 @@
+package costs
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sethvargo/go-githubactions"
+)
+
+// readOutputs parses the outputs written to the GITHUB_OUTPUT file, in the name<<delimiter format.
+func readOutputs(t *testing.T, path string) map[string]string {
+	t.Helper()
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read outputs: %v", err)
+	}
+	outputs := map[string]string{}
+	lines := strings.Split(string(raw), "\n")
+	for i := 0; i+1 < len(lines); i++ {
+		if name, _, ok := strings.Cut(lines[i], "<<"); ok {
+			outputs[name] = lines[i+1]
+			i += 2
+		}
+	}
+	return outputs
+}
+
+func newTestCostData() *CostResponseData {
+	costData := &CostResponseData{
+		InstanceType:      "m7i.large",
+		Region:            "us-east-1",
+		InstanceLifecycle: "spot",
+		DurationMinutes:   12.345,
+		TotalCost:         0.01234,
+		ListCost:          0.02,
+		Discount:          38.3,
+		Storage:           &CostComponent{Description: "gp3, 40 GiB", Cost: 0.001},
+	}
+	costData.Github.TotalCost = 0.0988
+	costData.Savings.Amount = 0.0855
+	costData.Savings.Percentage = 86.5
+	return costData
+}
+
+func TestSetCostOutputs(t *testing.T) {
+	outputFile := filepath.Join(t.TempDir(), "output")
+	t.Setenv("GITHUB_OUTPUT", outputFile)
+	action := githubactions.New(githubactions.WithWriter(&bytes.Buffer{}))
+
+	setCostOutputs(action, newTestCostData())
+
+	outputs := readOutputs(t, outputFile)
+	for name, want := range map[string]string{
+		"cost":                   "0.0123",
+		"list_cost":              "0.0200",
+		"total_cost":             "0.0133",
+		"github_equivalent_cost": "0.0988",
+		"savings":                "0.0855",
+		"duration_minutes":       "12.35",
+		"instance_type":          "m7i.large",
+		"lifecycle":              "spot",
+	} {
+		if got, ok := outputs[name]; !ok || got != want {
+			t.Errorf("output %s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestWriteCostReport(t *testing.T) {
+	// The report directory is created if needed
+	path := filepath.Join(t.TempDir(), "reports", "cost.json")
+	if err := writeCostReport(path, newTestCostData()); err != nil {
+		t.Fatalf("writeCostReport() error = %v", err)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read cost report: %v", err)
+	}
+	report := &CostResponseData{}
+	if err := json.Unmarshal(raw, report); err != nil {
+		t.Fatalf("failed to parse cost report: %v", err)
+	}
+	if report.InstanceType != "m7i.large" || report.TotalCost != 0.01234 || report.Github.TotalCost != 0.0988 || report.Savings.Percentage != 86.5 {
+		t.Errorf("unexpected cost report: %+v", report)
+	}
+	if report.Storage == nil || report.Storage.Cost != 0.001 || report.Carbon != nil {
+		t.Errorf("unexpected cost components in report: storage %+v, carbon %+v", report.Storage, report.Carbon)
+	}
+	for _, key := range []string{`"instanceType": "m7i.large"`, `"durationMinutes": 12.345`, `"listCost": 0.02`} {
+		if !strings.Contains(string(raw), key) {
+			t.Errorf("cost report is missing %s:\n%s", key, raw)
+		}
+	}
+}
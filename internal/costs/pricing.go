@@ -0,0 +1,276 @@
+package costs
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/runs-on/action/internal/config"
+	"github.com/runs-on/action/internal/utils"
+	"github.com/sethvargo/go-githubactions"
+)
+
+// Embedded snapshot of instance prices, used when no pricing dataset is configured.
+//
+//go:embed pricing.json
+var embeddedPricing []byte
+
+// PricingDataset holds hourly instance prices per region, used to compute costs without the cost API.
+type PricingDataset struct {
+	Source    string                   `json:"source"`
+	UpdatedAt string                   `json:"updatedAt"`
+	Regions   map[string]RegionPricing `json:"regions"`
+}
+
+// RegionPricing holds the prices of a single region.
+type RegionPricing struct {
+	EBS          map[string]VolumePricing   `json:"ebs"`
+	DataTransfer DataTransferPricing        `json:"dataTransfer"`
+	Instances    map[string]InstancePricing `json:"instances"`
+}
+
+// VolumePricing holds the monthly prices (USD) of an EBS volume type.
+// Provisioned IOPS and throughput are only charged above the included amounts.
+type VolumePricing struct {
+	PerGBMonth   float64 `json:"perGBMonth"`
+	PerIopsMonth float64 `json:"perIopsMonth,omitempty"`
+	PerMBpsMonth float64 `json:"perMBpsMonth,omitempty"`
+	IncludedIops int32   `json:"includedIops,omitempty"`
+	IncludedMBps int32   `json:"includedMBps,omitempty"`
+}
+
+// DataTransferPricing holds the per-GB prices (USD) of data transfer, in either direction.
+type DataTransferPricing struct {
+	NatGatewayPerGB float64 `json:"natGatewayPerGB"`
+	CrossAzPerGB    float64 `json:"crossAzPerGB"`
+}
+
+// InstancePricing holds the characteristics and hourly Linux prices (USD) of an instance type.
+// SpotByZone overrides the regional spot price for a given zone ID or zone name.
+type InstancePricing struct {
+	Arch       string             `json:"arch"`
+	VCPUs      int                `json:"vcpus"`
+	MemoryGiB  float64            `json:"memoryGiB"`
+	OnDemand   float64            `json:"onDemand"`
+	Spot       float64            `json:"spot,omitempty"`
+	SpotByZone map[string]float64 `json:"spotByZone,omitempty"`
+}
+
+// LoadPricingDataset loads a pricing dataset from an s3://bucket/key URL or a local file path.
+// An empty location returns the embedded snapshot.
+func LoadPricingDataset(ctx context.Context, location string, optFns ...func(*s3.Options)) (*PricingDataset, error) {
+	var raw []byte
+	switch {
+	case location == "":
+		raw = embeddedPricing
+	case strings.HasPrefix(location, "s3://"):
+		bucket, key, err := utils.ParseS3URL(location)
+		if err != nil {
+			return nil, err
+		}
+		client, err := utils.GetS3ClientFromEC2IMDS(ctx, optFns...)
+		if err != nil {
+			return nil, err
+		}
+		object, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+		if err != nil {
+			return nil, fmt.Errorf("failed to download pricing dataset from %s: %w", location, err)
+		}
+		defer object.Body.Close()
+		if raw, err = io.ReadAll(object.Body); err != nil {
+			return nil, fmt.Errorf("failed to read pricing dataset from %s: %w", location, err)
+		}
+	default:
+		var err error
+		if raw, err = os.ReadFile(location); err != nil {
+			return nil, fmt.Errorf("failed to read pricing dataset: %w", err)
+		}
+	}
+
+	dataset := &PricingDataset{}
+	if err := json.Unmarshal(raw, dataset); err != nil {
+		return nil, fmt.Errorf("failed to parse pricing dataset: %w", err)
+	}
+	if dataset.Source == "" {
+		dataset.Source = location
+	}
+	return dataset, nil
+}
+
+// Instance returns the pricing of an instance type in a region.
+func (d *PricingDataset) Instance(region, instanceType string) (InstancePricing, error) {
+	regionPricing, ok := d.Regions[region]
+	if !ok {
+		return InstancePricing{}, fmt.Errorf("no pricing for region %s", region)
+	}
+	instance, ok := regionPricing.Instances[instanceType]
+	if !ok {
+		return InstancePricing{}, fmt.Errorf("no pricing for instance type %s in region %s", instanceType, region)
+	}
+	return instance, nil
+}
+
+// InstanceSpecs returns the pricing of an instance type in any region of the dataset, for its architecture, vCPUs and memory,
+// which do not depend on the region. Regions are looked up in alphabetical order, so that prices are deterministic.
+func (d *PricingDataset) InstanceSpecs(instanceType string) (InstancePricing, error) {
+	regions := make([]string, 0, len(d.Regions))
+	for region := range d.Regions {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+	for _, region := range regions {
+		if instance, ok := d.Regions[region].Instances[instanceType]; ok {
+			return instance, nil
+		}
+	}
+	return InstancePricing{}, fmt.Errorf("no specifications for instance type %s", instanceType)
+}
+
+// pricingCache memoizes loaded pricing datasets by location.
+var pricingCache = map[string]*PricingDataset{}
+
+// loadPricingDataset loads the pricing dataset configured for the action, once.
+func loadPricingDataset(ctx context.Context, cfg *config.Config) (*PricingDataset, error) {
+	if dataset, ok := pricingCache[cfg.PricingDataset]; ok {
+		return dataset, nil
+	}
+	dataset, err := LoadPricingDataset(ctx, cfg.PricingDataset, utils.S3EndpointOptions(cfg.S3Endpoint, cfg.S3PathStyle))
+	if err != nil {
+		return nil, err
+	}
+	pricingCache[cfg.PricingDataset] = dataset
+	return dataset, nil
+}
+
+// HasRegion returns whether the dataset has prices for the given region.
+func (d *PricingDataset) HasRegion(region string) bool {
+	_, ok := d.Regions[region]
+	return ok
+}
+
+// checkPricingCoverage returns whether the configured pricing dataset covers the region of the instance.
+// When it does not, a single message is logged and the features computed from the dataset are skipped,
+// instead of each of them failing separately. The message is only a notice annotation for a dataset
+// given in the 'pricing_dataset' input, since the embedded snapshot does not cover all the regions by design.
+func checkPricingCoverage(action *githubactions.Action, cfg *config.Config, region string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+
+	dataset, err := loadPricingDataset(ctx, cfg)
+	if err != nil {
+		action.Warningf("Failed to load pricing dataset, skipping the costs computed from it: %v", err)
+		return false
+	}
+	if dataset.HasRegion(region) {
+		return true
+	}
+
+	message := fmt.Sprintf("The pricing dataset (%s) does not cover region %s: skipping the early cost budget check, storage and data transfer costs and the what-if cost comparison.", dataset.Source, region)
+	if cfg.PricingDataset == "" {
+		action.Infof("%s Set the 'pricing_dataset' input to a dataset covering this region to enable them.", message)
+	} else {
+		action.WithFieldsMap(map[string]string{"title": "Pricing dataset"}).Noticef("%s", message)
+	}
+	return false
+}
+
+// HourlyPrice returns the hourly price of an instance type for the given lifecycle ("spot" or "on-demand").
+// Spot prices are looked up by zone ID first, then by zone name, then fall back to the regional spot price.
+func (d *PricingDataset) HourlyPrice(region, instanceType, lifecycle, az, zoneId string) (float64, error) {
+	instance, err := d.Instance(region, instanceType)
+	if err != nil {
+		return 0, err
+	}
+
+	if lifecycle != "spot" {
+		return instance.OnDemand, nil
+	}
+	for _, zone := range []string{zoneId, az} {
+		if price, ok := instance.SpotByZone[zone]; ok && zone != "" {
+			return price, nil
+		}
+	}
+	if instance.Spot == 0 {
+		return 0, fmt.Errorf("no spot pricing for instance type %s in region %s", instanceType, region)
+	}
+	return instance.Spot, nil
+}
+
+// ComputeCost computes the cost of the instance described by the payload, from its launch time until now.
+func (d *PricingDataset) ComputeCost(payload CostRequestPayload, now time.Time) (*CostResponseData, error) {
+	if payload.Platform != "linux" {
+		return nil, fmt.Errorf("local pricing only covers linux instances, got %s", payload.Platform)
+	}
+
+	startedAt, err := time.Parse(time.RFC3339, payload.StartedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse instance launch time: %w", err)
+	}
+
+	hourlyPrice, err := d.HourlyPrice(payload.Region, payload.InstanceType, payload.InstanceLifecycle, payload.Az, payload.ZoneId)
+	if err != nil {
+		return nil, err
+	}
+
+	durationMinutes := now.Sub(startedAt).Minutes()
+	return &CostResponseData{
+		InstanceType:      payload.InstanceType,
+		Region:            payload.Region,
+		Platform:          payload.Platform,
+		Arch:              payload.Arch,
+		Az:                payload.Az,
+		ZoneId:            payload.ZoneId,
+		InstanceLifecycle: payload.InstanceLifecycle,
+		DurationMinutes:   durationMinutes,
+		TotalCost:         hourlyPrice * durationMinutes / 60,
+		Source:            fmt.Sprintf("local dataset (%s)", d.Source),
+	}, nil
+}
+
+// StorageCost returns the cost of an EBS volume for the given duration.
+func (d *PricingDataset) StorageCost(region string, volume volumeInfo, duration time.Duration) (float64, error) {
+	regionPricing, ok := d.Regions[region]
+	if !ok {
+		return 0, fmt.Errorf("no pricing for region %s", region)
+	}
+	pricing, ok := regionPricing.EBS[volume.VolumeType]
+	if !ok {
+		return 0, fmt.Errorf("no pricing for volume type %s in region %s", volume.VolumeType, region)
+	}
+
+	monthly := float64(volume.SizeGiB) * pricing.PerGBMonth
+	if extra := volume.Iops - pricing.IncludedIops; pricing.PerIopsMonth > 0 && extra > 0 {
+		monthly += float64(extra) * pricing.PerIopsMonth
+	}
+	if extra := volume.ThroughputMBps - pricing.IncludedMBps; pricing.PerMBpsMonth > 0 && extra > 0 {
+		monthly += float64(extra) * pricing.PerMBpsMonth
+	}
+	return monthly * duration.Hours() / hoursPerMonth, nil
+}
+
+// DataTransferCost returns the cost of transferring the given amount of bytes,
+// through a NAT gateway ("nat") or across availability zones ("cross-az").
+func (d *PricingDataset) DataTransferCost(region, mode string, bytes float64) (float64, error) {
+	regionPricing, ok := d.Regions[region]
+	if !ok {
+		return 0, fmt.Errorf("no pricing for region %s", region)
+	}
+
+	gigabytes := bytes / (1 << 30)
+	switch mode {
+	case "nat":
+		return gigabytes * regionPricing.DataTransfer.NatGatewayPerGB, nil
+	case "cross-az":
+		return gigabytes * regionPricing.DataTransfer.CrossAzPerGB, nil
+	default:
+		return 0, fmt.Errorf("unsupported data transfer mode: %s", mode)
+	}
+}
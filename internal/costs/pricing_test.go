@@ -0,0 +1,166 @@
+package costs
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/runs-on/action/internal/config"
+	"github.com/sethvargo/go-githubactions"
+)
+
+func TestLoadPricingDatasetEmbedded(t *testing.T) {
+	dataset, err := LoadPricingDataset(context.Background(), "")
+	if err != nil {
+		t.Fatalf("LoadPricingDataset() error = %v", err)
+	}
+
+	instance, err := dataset.Instance("us-east-1", "m7i-flex.large")
+	if err != nil {
+		t.Fatalf("Instance() error = %v", err)
+	}
+	if instance.OnDemand <= 0 || instance.Spot <= 0 || instance.VCPUs != 2 || instance.Arch != "x64" {
+		t.Fatalf("unexpected embedded pricing for m7i-flex.large: %+v", instance)
+	}
+}
+
+func TestPricingDatasetComputeCost(t *testing.T) {
+	dataset := &PricingDataset{
+		Source: "test",
+		Regions: map[string]RegionPricing{
+			"us-east-1": {Instances: map[string]InstancePricing{
+				"m7g.large": {Arch: "arm64", OnDemand: 0.6, Spot: 0.3, SpotByZone: map[string]float64{"use1-az4": 0.12}},
+			}},
+		},
+	}
+	startedAt := time.Date(2025, 6, 30, 14, 0, 0, 0, time.UTC)
+	payload := CostRequestPayload{
+		InstanceType:      "m7g.large",
+		InstanceLifecycle: "on-demand",
+		Region:            "us-east-1",
+		Az:                "us-east-1a",
+		ZoneId:            "use1-az4",
+		Arch:              "arm64",
+		StartedAt:         startedAt.Format(time.RFC3339),
+		Platform:          "linux",
+	}
+
+	tests := []struct {
+		name      string
+		lifecycle string
+		zoneId    string
+		want      float64
+	}{
+		{"on-demand", "on-demand", "use1-az4", 0.1},
+		{"spot with zone price", "spot", "use1-az4", 0.02},
+		{"spot with regional price", "spot", "use1-az1", 0.05},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload.InstanceLifecycle = tt.lifecycle
+			payload.ZoneId = tt.zoneId
+			costData, err := dataset.ComputeCost(payload, startedAt.Add(10*time.Minute))
+			if err != nil {
+				t.Fatalf("ComputeCost() error = %v", err)
+			}
+			if costData.DurationMinutes != 10 {
+				t.Errorf("DurationMinutes = %v, want 10", costData.DurationMinutes)
+			}
+			if math.Abs(costData.TotalCost-tt.want) > 1e-9 {
+				t.Errorf("TotalCost = %v, want %v", costData.TotalCost, tt.want)
+			}
+		})
+	}
+
+	payload.InstanceType = "unknown.large"
+	if _, err := dataset.ComputeCost(payload, startedAt.Add(time.Minute)); err == nil {
+		t.Fatalf("expected an error for an unknown instance type")
+	}
+}
+
+func TestPricingDatasetStorageAndDataTransferCost(t *testing.T) {
+	dataset, err := LoadPricingDataset(context.Background(), "")
+	if err != nil {
+		t.Fatalf("LoadPricingDataset() error = %v", err)
+	}
+
+	// 100 GiB at $0.08, 3000 extra IOPS at $0.005 and 125 extra MB/s at $0.04, for a full month
+	volume := volumeInfo{VolumeType: "gp3", SizeGiB: 100, Iops: 6000, ThroughputMBps: 250}
+	cost, err := dataset.StorageCost("us-east-1", volume, hoursPerMonth*time.Hour)
+	if err != nil {
+		t.Fatalf("StorageCost() error = %v", err)
+	}
+	if math.Abs(cost-28) > 1e-9 {
+		t.Errorf("StorageCost() = %v, want 28", cost)
+	}
+
+	cost, err = dataset.DataTransferCost("us-east-1", "nat", 10*(1<<30))
+	if err != nil {
+		t.Fatalf("DataTransferCost() error = %v", err)
+	}
+	if math.Abs(cost-0.45) > 1e-9 {
+		t.Errorf("DataTransferCost() = %v, want 0.45", cost)
+	}
+}
+
+func TestPricingDatasetInstanceSpecs(t *testing.T) {
+	dataset, err := LoadPricingDataset(context.Background(), "")
+	if err != nil {
+		t.Fatalf("LoadPricingDataset() error = %v", err)
+	}
+
+	// The embedded snapshot does not cover eu-west-1, but the specifications do not depend on the region
+	if _, err := dataset.Instance("eu-west-1", "m7i-flex.large"); err == nil {
+		t.Fatalf("expected no eu-west-1 pricing in the embedded snapshot")
+	}
+	instance, err := dataset.InstanceSpecs("m7i-flex.large")
+	if err != nil {
+		t.Fatalf("InstanceSpecs() error = %v", err)
+	}
+	if instance.VCPUs != 2 || instance.MemoryGiB != 8 || instance.Arch != "x64" {
+		t.Errorf("InstanceSpecs() = %+v, want 2 vCPUs, 8 GiB and x64", instance)
+	}
+
+	if _, err := dataset.InstanceSpecs("unknown.large"); err == nil {
+		t.Errorf("expected an error for an unknown instance type")
+	}
+}
+
+func TestCheckPricingCoverage(t *testing.T) {
+	// A custom dataset covering us-east-1 only
+	datasetFile := filepath.Join(t.TempDir(), "pricing.json")
+	if err := os.WriteFile(datasetFile, []byte(`{"source": "custom", "regions": {"us-east-1": {}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		dataset    string
+		region     string
+		want       bool
+		wantNotice bool
+	}{
+		{"embedded, covered region", "", "us-east-1", true, false},
+		// The embedded snapshot does not cover all the regions, which is only logged
+		{"embedded, uncovered region", "", "eu-west-1", false, false},
+		{"custom, uncovered region", datasetFile, "eu-west-1", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			action := githubactions.New(githubactions.WithWriter(&output))
+			cfg := &config.Config{PricingDataset: tt.dataset}
+			if got := checkPricingCoverage(action, cfg, tt.region); got != tt.want {
+				t.Errorf("checkPricingCoverage() = %t, want %t", got, tt.want)
+			}
+			if got := strings.Contains(output.String(), "::notice title=Pricing dataset::"); got != tt.wantNotice {
+				t.Errorf("notice emitted = %t, want %t: %q", got, tt.wantNotice, output.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,314 @@
+package costs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/runs-on/action/internal/config"
+	"github.com/runs-on/action/internal/utils"
+	"github.com/sethvargo/go-githubactions"
+)
+
+// Cost records are stored in the RunsOn S3 cache bucket, under <prefix>/<repository>/<run id>/.
+const costRecordsPrefix = "cache/runs-on/costs"
+
+const (
+	rollupTimeout = 30 * time.Second
+	// Number of most expensive jobs listed in the rollup summary
+	rollupTopJobs = 5
+)
+
+// CostRecord is the cost of a single job, saved for the workflow run cost rollup.
+type CostRecord struct {
+	Label      string            `json:"label"`
+	Job        string            `json:"job"`
+	RunAttempt string            `json:"runAttempt"`
+	RunnerName string            `json:"runnerName"`
+	SavedAt    time.Time         `json:"savedAt"`
+	Cost       *CostResponseData `json:"cost"`
+}
+
+var unsafeKeyChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// runRecordsPrefix returns the S3 prefix holding the cost records of the current workflow run.
+func runRecordsPrefix() (string, error) {
+	repository := os.Getenv("GITHUB_REPOSITORY")
+	runID := os.Getenv("GITHUB_RUN_ID")
+	if repository == "" || runID == "" {
+		return "", fmt.Errorf("GITHUB_REPOSITORY and GITHUB_RUN_ID environment variables are required")
+	}
+	return fmt.Sprintf("%s/%s/%s/", costRecordsPrefix, repository, runID), nil
+}
+
+// newCostRecord builds the cost record of the current job.
+// The label defaults to the job id, which is shared by all legs of a matrix job: the rollup then tells them apart by runner name.
+func newCostRecord(cfg *config.Config, costData *CostResponseData) *CostRecord {
+	label := cfg.CostLabel
+	if label == "" {
+		label = os.Getenv("GITHUB_JOB")
+	}
+	return &CostRecord{
+		Label:      label,
+		Job:        os.Getenv("GITHUB_JOB"),
+		RunAttempt: os.Getenv("GITHUB_RUN_ATTEMPT"),
+		RunnerName: os.Getenv("RUNS_ON_RUNNER_NAME"),
+		SavedAt:    time.Now().UTC(),
+		Cost:       costData,
+	}
+}
+
+// saveCostRecord saves the cost of the current job in the S3 cache bucket, for the workflow run cost rollup.
+// Records are keyed by runner, so that computing costs several times in a job keeps the latest one.
+func saveCostRecord(cfg *config.Config, costData *CostResponseData) (string, error) {
+	bucket := os.Getenv("RUNS_ON_S3_BUCKET_CACHE")
+	if bucket == "" {
+		return "", fmt.Errorf("RUNS_ON_S3_BUCKET_CACHE environment variable is not set")
+	}
+	prefix, err := runRecordsPrefix()
+	if err != nil {
+		return "", err
+	}
+
+	record := newCostRecord(cfg, costData)
+	body, err := json.Marshal(record)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal cost record: %w", err)
+	}
+	key := prefix + unsafeKeyChars.ReplaceAllString(fmt.Sprintf("%s-%s-%s", record.RunAttempt, record.Label, record.RunnerName), "_") + ".json"
+
+	ctx, cancel := context.WithTimeout(context.Background(), rollupTimeout)
+	defer cancel()
+	client, err := utils.GetS3ClientFromEC2IMDS(ctx, utils.S3EndpointOptions(cfg.S3Endpoint, cfg.S3PathStyle))
+	if err != nil {
+		return "", err
+	}
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/json"),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload cost record: %w", err)
+	}
+	return fmt.Sprintf("s3://%s/%s", bucket, key), nil
+}
+
+// loadCostRecords reads all the cost records saved for the current workflow run.
+// Records that cannot be read are skipped with a warning, so that a single bad record does not hide the costs of
+// the other jobs, and their number is returned along with the records.
+func loadCostRecords(ctx context.Context, action *githubactions.Action, client *s3.Client, bucket, prefix string) ([]*CostRecord, int, error) {
+	var records []*CostRecord
+	skipped := 0
+	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{Bucket: aws.String(bucket), Prefix: aws.String(prefix)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to list cost records: %w", err)
+		}
+		for _, object := range page.Contents {
+			record, err := loadCostRecord(ctx, client, bucket, aws.ToString(object.Key))
+			if err != nil {
+				action.Warningf("Skipping cost record: %v", err)
+				skipped++
+				continue
+			}
+			records = append(records, record)
+		}
+	}
+	return records, skipped, nil
+}
+
+func loadCostRecord(ctx context.Context, client *s3.Client, bucket, key string) (*CostRecord, error) {
+	object, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to download cost record %s: %w", key, err)
+	}
+	defer object.Body.Close()
+
+	raw, err := io.ReadAll(object.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cost record %s: %w", key, err)
+	}
+	record := &CostRecord{}
+	if err := json.Unmarshal(raw, record); err != nil || record.Cost == nil {
+		return nil, fmt.Errorf("failed to parse cost record %s: %v", key, err)
+	}
+	return record, nil
+}
+
+// DisplayRollup reads the cost records saved by every job of the current workflow run,
+// and writes a workflow-level cost summary.
+func DisplayRollup(action *githubactions.Action, cfg *config.Config) error {
+	bucket := os.Getenv("RUNS_ON_S3_BUCKET_CACHE")
+	if bucket == "" {
+		return fmt.Errorf("RUNS_ON_S3_BUCKET_CACHE environment variable is not set")
+	}
+	prefix, err := runRecordsPrefix()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rollupTimeout)
+	defer cancel()
+	client, err := utils.GetS3ClientFromEC2IMDS(ctx, utils.S3EndpointOptions(cfg.S3Endpoint, cfg.S3PathStyle))
+	if err != nil {
+		return err
+	}
+	records, skipped, err := loadCostRecords(ctx, action, client, bucket, prefix)
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 && skipped > 0 {
+		action.Warningf("None of the %d cost records found for workflow run %s could be read.", skipped, os.Getenv("GITHUB_RUN_ID"))
+		return nil
+	}
+	if len(records) == 0 {
+		action.Warningf("No cost records found for workflow run %s. Jobs save their costs in their post-execution step, make sure this job runs after them.", os.Getenv("GITHUB_RUN_ID"))
+		return nil
+	}
+
+	rollup := computeRollup(records)
+	rollup.Skipped = skipped
+	action.SetOutput("total_cost", fmt.Sprintf("%.4f", rollup.TotalCost))
+	action.SetOutput("github_equivalent_cost", fmt.Sprintf("%.4f", rollup.GithubCost))
+	action.SetOutput("savings", fmt.Sprintf("%.4f", rollup.Savings))
+
+	summary := renderRollup(rollup)
+	fmt.Print(summary)
+	action.AddStepSummary(summary)
+	action.Infof("Workflow run cost summary added to job summary.")
+	return nil
+}
+
+// costRollup aggregates the costs of all the jobs of a workflow run.
+type costRollup struct {
+	Records    []*CostRecord
+	TotalCost  float64
+	GithubCost float64
+	// Savings only account for jobs with a known GitHub equivalent cost
+	Savings           float64
+	SavingsPercentage float64
+	// Number of records that could not be read: the totals are then partial
+	Skipped int
+}
+
+func computeRollup(records []*CostRecord) *costRollup {
+	rollup := &costRollup{Records: records}
+	comparableCost := 0.0
+	for _, record := range records {
+		rollup.TotalCost += record.Cost.JobCost()
+		if record.Cost.Github.TotalCost > 0 {
+			rollup.GithubCost += record.Cost.Github.TotalCost
+			comparableCost += record.Cost.JobCost()
+		}
+	}
+	if rollup.GithubCost > 0 {
+		rollup.Savings = rollup.GithubCost - comparableCost
+		rollup.SavingsPercentage = rollup.Savings / rollup.GithubCost * 100
+	}
+	sort.SliceStable(rollup.Records, func(i, j int) bool {
+		if rollup.Records[i].Label != rollup.Records[j].Label {
+			return rollup.Records[i].Label < rollup.Records[j].Label
+		}
+		return rollup.Records[i].SavedAt.Before(rollup.Records[j].SavedAt)
+	})
+	return rollup
+}
+
+// jobName returns the label of a record, with the run attempt for re-run jobs.
+// Records sharing their label with other records, such as the legs of a matrix job without
+// a cost_label, are told apart by their runner name.
+func (r *CostRecord) jobName(labelCounts map[string]int) string {
+	details := []string{}
+	if labelCounts[r.Label] > 1 && r.RunnerName != "" {
+		details = append(details, r.RunnerName)
+	}
+	if r.RunAttempt != "" && r.RunAttempt != "1" {
+		details = append(details, "attempt "+r.RunAttempt)
+	}
+	if len(details) == 0 {
+		return r.Label
+	}
+	return fmt.Sprintf("%s (%s)", r.Label, strings.Join(details, ", "))
+}
+
+func renderRollup(rollup *costRollup) string {
+	labelCounts := map[string]int{}
+	for _, record := range rollup.Records {
+		labelCounts[record.Label]++
+	}
+
+	headers := []string{"job", "instance type", "lifecycle", "duration", "cost", "GitHub equivalent cost"}
+	rows := [][]string{}
+	for _, record := range rollup.Records {
+		githubCostStr := "n/a"
+		if record.Cost.Github.TotalCost > 0 {
+			githubCostStr = fmt.Sprintf("$%.4f", record.Cost.Github.TotalCost)
+		}
+		rows = append(rows, []string{
+			record.jobName(labelCounts),
+			record.Cost.InstanceType,
+			record.Cost.InstanceLifecycle,
+			fmt.Sprintf("%.2f minutes", record.Cost.DurationMinutes),
+			fmt.Sprintf("$%.4f", record.Cost.JobCost()),
+			githubCostStr,
+		})
+	}
+
+	savingsStr := "n/a"
+	githubCostStr := "n/a"
+	if rollup.GithubCost > 0 {
+		githubCostStr = fmt.Sprintf("$%.4f", rollup.GithubCost)
+		savingsStr = fmt.Sprintf("$%.4f (%.1f%%)", rollup.Savings, rollup.SavingsPercentage)
+	}
+	jobsStr := fmt.Sprintf("%d", len(rollup.Records))
+	totalCostLabel := "Total cost"
+	if rollup.Skipped > 0 {
+		jobsStr = fmt.Sprintf("%d (%d skipped)", len(rollup.Records), rollup.Skipped)
+		totalCostLabel = "Total cost (partial)"
+	}
+	totalRows := [][]string{
+		{"Jobs", jobsStr},
+		{totalCostLabel, fmt.Sprintf("$%.4f", rollup.TotalCost)},
+		{"GitHub equivalent cost", githubCostStr},
+		{"Savings", savingsStr},
+	}
+
+	expensive := append([]*CostRecord{}, rollup.Records...)
+	sort.SliceStable(expensive, func(i, j int) bool { return expensive[i].Cost.JobCost() > expensive[j].Cost.JobCost() })
+	if len(expensive) > rollupTopJobs {
+		expensive = expensive[:rollupTopJobs]
+	}
+	expensiveRows := [][]string{}
+	for _, record := range expensive {
+		share := 0.0
+		if rollup.TotalCost > 0 {
+			share = record.Cost.JobCost() / rollup.TotalCost * 100
+		}
+		expensiveRows = append(expensiveRows, []string{record.jobName(labelCounts), record.Cost.InstanceType, fmt.Sprintf("$%.4f", record.Cost.JobCost()), fmt.Sprintf("%.1f%%", share)})
+	}
+
+	summaryBuilder := &strings.Builder{}
+	summaryBuilder.WriteString("## Workflow Run Cost Summary\n\n")
+	summaryBuilder.WriteString(utils.RenderMarkdownTable([]string{"metric", "value"}, totalRows))
+	if rollup.Skipped > 0 {
+		summaryBuilder.WriteString(fmt.Sprintf("\n%d cost records could not be read and are left out of the totals, see the warnings in the job log.\n", rollup.Skipped))
+	}
+	summaryBuilder.WriteString("\n### Most expensive jobs\n\n")
+	summaryBuilder.WriteString(utils.RenderMarkdownTable([]string{"job", "instance type", "cost", "share"}, expensiveRows))
+	summaryBuilder.WriteString("\n### Jobs\n\n")
+	summaryBuilder.WriteString(utils.RenderMarkdownTable(headers, rows))
+	summaryBuilder.WriteString("\n")
+	return summaryBuilder.String()
+}
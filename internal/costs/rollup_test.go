@@ -0,0 +1,141 @@
+package costs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/runs-on/action/internal/utils"
+	"github.com/sethvargo/go-githubactions"
+)
+
+func newTestRecord(label, attempt string, cost, githubCost float64) *CostRecord {
+	data := &CostResponseData{InstanceType: "m7i.large", InstanceLifecycle: "spot", DurationMinutes: 10, TotalCost: cost}
+	data.Github.TotalCost = githubCost
+	return &CostRecord{Label: label, RunAttempt: attempt, RunnerName: "runs-on--" + label, SavedAt: time.Now(), Cost: data}
+}
+
+func TestComputeRollup(t *testing.T) {
+	rollup := computeRollup([]*CostRecord{
+		newTestRecord("test-unit", "1", 0.01, 0.08),
+		newTestRecord("build", "1", 0.03, 0.16),
+		newTestRecord("test-integration", "2", 0.02, 0),
+	})
+
+	if math.Abs(rollup.TotalCost-0.06) > 1e-9 {
+		t.Errorf("TotalCost = %v, want 0.06", rollup.TotalCost)
+	}
+	// The job without a GitHub equivalent cost is left out of the savings
+	if math.Abs(rollup.GithubCost-0.24) > 1e-9 || math.Abs(rollup.Savings-0.20) > 1e-9 {
+		t.Errorf("GithubCost = %v, Savings = %v, want 0.24 and 0.20", rollup.GithubCost, rollup.Savings)
+	}
+	if rollup.Records[0].Label != "build" {
+		t.Errorf("expected records sorted by label, got %s first", rollup.Records[0].Label)
+	}
+
+	summary := renderRollup(rollup)
+	for _, expected := range []string{
+		"| Total cost             | $0.0600         |",
+		"| Savings                | $0.2000 (83.3%) |",
+		"| build                        | m7i.large     | $0.0300 | 50.0% |",
+		"| test-integration (attempt 2) | m7i.large     | spot      | 10.00 minutes | $0.0200 | n/a                    |",
+	} {
+		if !strings.Contains(summary, expected) {
+			t.Errorf("expected summary to contain %q, got:\n%s", expected, summary)
+		}
+	}
+}
+
+func TestRenderRollupTellsMatrixLegsApart(t *testing.T) {
+	first := newTestRecord("test", "1", 0.02, 0)
+	first.RunnerName = "runs-on--1-a"
+	second := newTestRecord("test", "2", 0.01, 0)
+	second.RunnerName = "runs-on--1-b"
+
+	summary := renderRollup(computeRollup([]*CostRecord{first, second, newTestRecord("build", "1", 0.03, 0)}))
+	for _, expected := range []string{
+		"| test (runs-on--1-a)            | m7i.large     | $0.0200 |",
+		"| test (runs-on--1-b, attempt 2) | m7i.large     | $0.0100 |",
+		"| build                          | m7i.large     | $0.0300 |",
+	} {
+		if !strings.Contains(summary, expected) {
+			t.Errorf("expected summary to contain %q, got:\n%s", expected, summary)
+		}
+	}
+}
+
+// newTestRecordsBucket serves the given cost records from a minimal S3-compatible server, with path-style addressing.
+func newTestRecordsBucket(t *testing.T, objects map[string]string) *s3.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		if key == "" {
+			keys := []string{}
+			for key := range objects {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><ListBucketResult><IsTruncated>false</IsTruncated>`)
+			for _, key := range keys {
+				fmt.Fprintf(w, "<Contents><Key>%s</Key></Contents>", key)
+			}
+			fmt.Fprint(w, "</ListBucketResult>")
+			return
+		}
+		body, ok := objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchKey</Code></Error>`)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return s3.New(s3.Options{Region: "us-east-1", Credentials: aws.AnonymousCredentials{}}, utils.S3EndpointOptions(srv.URL, true))
+}
+
+func TestLoadCostRecordsSkipsBadRecords(t *testing.T) {
+	record, err := json.Marshal(newTestRecord("build", "1", 0.03, 0.16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newTestRecordsBucket(t, map[string]string{
+		"costs/1-build.json":   string(record),
+		"costs/1-corrupt.json": "{not json",
+		"costs/1-empty.json":   "{}",
+	})
+	var out bytes.Buffer
+	action := githubactions.New(githubactions.WithWriter(&out))
+
+	records, skipped, err := loadCostRecords(context.Background(), action, client, "bucket", "costs/")
+	if err != nil {
+		t.Fatalf("loadCostRecords() error = %v", err)
+	}
+	if len(records) != 1 || records[0].Label != "build" || skipped != 2 {
+		t.Fatalf("got %d records and %d skipped, want 1 and 2", len(records), skipped)
+	}
+	for _, key := range []string{"costs/1-corrupt.json", "costs/1-empty.json"} {
+		if !strings.Contains(out.String(), "::warning::Skipping cost record: failed to parse cost record "+key) {
+			t.Errorf("expected a warning for %s, got:\n%s", key, out.String())
+		}
+	}
+
+	rollup := computeRollup(records)
+	rollup.Skipped = skipped
+	summary := renderRollup(rollup)
+	for _, expected := range []string{"| Jobs                   | 1 (2 skipped)   |", "| Total cost (partial)   | $0.0300         |", "2 cost records could not be read"} {
+		if !strings.Contains(summary, expected) {
+			t.Errorf("expected summary to contain %q, got:\n%s", expected, summary)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package costs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/runs-on/action/internal/config"
+	"github.com/runs-on/action/internal/monitoring"
+	"github.com/runs-on/action/internal/utils"
+	"github.com/sethvargo/go-githubactions"
+)
+
+const hoursPerMonth = 730
+
+// CostComponent is a cost of the job on top of the instance compute cost, computed locally.
+type CostComponent struct {
+	Description string  `json:"description"`
+	Cost        float64 `json:"cost"`
+}
+
+// volumeInfo describes the root EBS volume of the instance.
+type volumeInfo struct {
+	VolumeType     string
+	SizeGiB        int32
+	Iops           int32
+	ThroughputMBps int32
+}
+
+// addCostComponents computes the storage and data transfer costs of the job, and attaches them to the cost data.
+// Failures are only reported, since these components are estimates on top of the compute cost.
+func addCostComponents(action *githubactions.Action, cfg *config.Config, costData *CostResponseData) {
+	if !cfg.StorageCosts && cfg.DataTransfer == "none" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+
+	dataset, err := loadPricingDataset(ctx, cfg)
+	if err != nil {
+		action.Warningf("Failed to load pricing dataset, skipping storage and data transfer costs: %v", err)
+		return
+	}
+	duration := time.Duration(costData.DurationMinutes * float64(time.Minute))
+
+	if cfg.StorageCosts {
+		volume, err := getRootVolume(ctx)
+		if err != nil {
+			action.Infof("Could not determine root volume, skipping storage cost: %v", err)
+		} else if cost, err := dataset.StorageCost(costData.Region, *volume, duration); err != nil {
+			action.Infof("Could not compute storage cost: %v", err)
+		} else {
+			costData.Storage = &CostComponent{
+				Description: fmt.Sprintf("%s, %d GiB", volume.VolumeType, volume.SizeGiB),
+				Cost:        cost,
+			}
+		}
+	}
+
+	if cfg.DataTransfer != "none" {
+		received, sent, err := monitoring.NetworkBytes(cfg.NetworkInterface)
+		if err != nil {
+			action.Infof("Could not read network counters, skipping data transfer cost: %v", err)
+		} else if cost, err := dataset.DataTransferCost(costData.Region, cfg.DataTransfer, received+sent); err != nil {
+			action.Infof("Could not compute data transfer cost: %v", err)
+		} else {
+			costData.DataTransfer = &CostComponent{
+				Description: fmt.Sprintf("%s, %.2f GiB", cfg.DataTransfer, (received+sent)/(1<<30)),
+				Cost:        cost,
+			}
+		}
+	}
+}
+
+// getRootVolume returns the root volume of the instance, from EC2 DescribeVolumes when allowed,
+// or from the size of the root filesystem otherwise (assuming a gp3 volume).
+func getRootVolume(ctx context.Context) (*volumeInfo, error) {
+	volume, err := describeRootVolume(ctx)
+	if err == nil {
+		return volume, nil
+	}
+
+	sizeGiB, fsErr := rootFilesystemSizeGiB()
+	if fsErr != nil {
+		return nil, fmt.Errorf("%w, and %w", err, fsErr)
+	}
+	return &volumeInfo{VolumeType: "gp3", SizeGiB: sizeGiB}, nil
+}
+
+// describeRootVolume finds the volume attached to the instance on its root device
+func describeRootVolume(ctx context.Context) (*volumeInfo, error) {
+	instanceID := os.Getenv("RUNS_ON_INSTANCE_ID")
+	if instanceID == "" {
+		return nil, fmt.Errorf("RUNS_ON_INSTANCE_ID not set")
+	}
+
+	cfg, err := utils.GetAWSClientFromEC2IMDS(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := ec2.NewFromConfig(*cfg).DescribeVolumes(ctx, &ec2.DescribeVolumesInput{
+		Filters: []types.Filter{
+			{Name: aws.String("attachment.instance-id"), Values: []string{instanceID}},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe volumes: %w", err)
+	}
+	if len(result.Volumes) == 0 {
+		return nil, fmt.Errorf("no volume attached to instance %s", instanceID)
+	}
+
+	rootDevice := getRootDeviceName(ctx)
+	volume := result.Volumes[0]
+	for _, candidate := range result.Volumes {
+		for _, attachment := range candidate.Attachments {
+			if aws.ToString(attachment.Device) == rootDevice {
+				volume = candidate
+			}
+		}
+	}
+
+	return &volumeInfo{
+		VolumeType:     string(volume.VolumeType),
+		SizeGiB:        aws.ToInt32(volume.Size),
+		Iops:           aws.ToInt32(volume.Iops),
+		ThroughputMBps: aws.ToInt32(volume.Throughput),
+	}, nil
+}
+
+// getRootDeviceName returns the root device name (e.g. /dev/xvda) from IMDS, or an empty string
+func getRootDeviceName(ctx context.Context) string {
+	output, err := imds.New(imds.Options{}).GetMetadata(ctx, &imds.GetMetadataInput{Path: "block-device-mapping/root"})
+	if err != nil {
+		return ""
+	}
+	defer output.Content.Close()
+	raw, err := io.ReadAll(output.Content)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(raw))
+}
+
+// rootFilesystemSizeGiB returns the size of the root filesystem, rounded up to the GiB
+func rootFilesystemSizeGiB() (int32, error) {
+	output, err := exec.Command("df", "-k", "/").Output()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get root filesystem size: %w", err)
+	}
+
+	lines := strings.Split(string(output), "\n")
+	if len(lines) < 2 || len(strings.Fields(lines[1])) < 2 {
+		return 0, fmt.Errorf("unexpected df output: %s", string(output))
+	}
+	blocks, err := strconv.ParseFloat(strings.Fields(lines[1])[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse root filesystem size: %w", err)
+	}
+	return int32(math.Ceil(blocks * 1024 / (1 << 30))), nil
+}
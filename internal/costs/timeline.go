@@ -0,0 +1,166 @@
+package costs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/runs-on/action/internal/utils"
+)
+
+// Runner diagnostic log entries look like:
+//
+//	[2025-04-02 10:00:01Z INFO StepsRunner] Processing step: DisplayName='Run actions/checkout@v4'
+var (
+	workerLogTimestamp = regexp.MustCompile(`^\[(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2})Z `)
+	workerLogStepStart = regexp.MustCompile(`Processing step: DisplayName='(.*)'`)
+	workerLogStepEnd   = regexp.MustCompile(`Step result: `)
+)
+
+const bootStepName = "Boot and queue"
+
+// stepTiming is the start and end time of a job step.
+type stepTiming struct {
+	Name  string
+	Start time.Time
+	End   time.Time
+}
+
+func (s stepTiming) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// runnerDiagDir returns the directory holding the runner diagnostic logs, next to the runner work directory.
+func runnerDiagDir() string {
+	return filepath.Join(os.Getenv("RUNNER_TEMP"), "..", "..", "_diag")
+}
+
+// latestWorkerLog returns the most recent worker log in the runner diagnostic directory.
+func latestWorkerLog(diagDir string) (string, error) {
+	matches, err := filepath.Glob(filepath.Join(diagDir, "Worker_*.log"))
+	if err != nil {
+		return "", err
+	}
+	if len(matches) == 0 {
+		return "", fmt.Errorf("no worker log found in %s", diagDir)
+	}
+	// Worker logs are named after their creation time, e.g. Worker_20250402-100000-utc.log
+	sort.Strings(matches)
+	return matches[len(matches)-1], nil
+}
+
+// parseWorkerLog extracts step timings from a runner worker log.
+// A step ends with its result, or when the next step starts. A step still running ends at the given time.
+func parseWorkerLog(r io.Reader, now time.Time) ([]stepTiming, error) {
+	var steps []stepTiming
+	var current *stepTiming
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		match := workerLogTimestamp.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		timestamp, err := time.Parse("2006-01-02 15:04:05", match[1])
+		if err != nil {
+			continue
+		}
+
+		if start := workerLogStepStart.FindStringSubmatch(line); start != nil {
+			if current != nil {
+				current.End = timestamp
+				steps = append(steps, *current)
+			}
+			current = &stepTiming{Name: start[1], Start: timestamp}
+		} else if current != nil && workerLogStepEnd.MatchString(line) {
+			current.End = timestamp
+			steps = append(steps, *current)
+			current = nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read worker log: %w", err)
+	}
+	if current != nil {
+		current.End = now.UTC()
+		steps = append(steps, *current)
+	}
+	return steps, nil
+}
+
+// loadStepTimeline returns the timeline of the job steps, starting with the boot and queue time of the instance.
+func loadStepTimeline(launchedAt, now time.Time) ([]stepTiming, error) {
+	workerLog, err := latestWorkerLog(runnerDiagDir())
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(workerLog)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open worker log: %w", err)
+	}
+	defer file.Close()
+
+	steps, err := parseWorkerLog(file, now)
+	if err != nil {
+		return nil, err
+	}
+	if len(steps) > 0 && steps[0].Start.After(launchedAt) {
+		steps = append([]stepTiming{{Name: bootStepName, Start: launchedAt.UTC(), End: steps[0].Start}}, steps...)
+	}
+	return steps, nil
+}
+
+// renderTimeline renders the step timeline as a Mermaid Gantt chart and a table,
+// with the share of the instance cost of each step based on the per-minute rate of the instance.
+func renderTimeline(steps []stepTiming, costData *CostResponseData) string {
+	perMinute := 0.0
+	if costData.DurationMinutes > 0 {
+		perMinute = costData.TotalCost / costData.DurationMinutes
+	}
+	total := steps[len(steps)-1].End.Sub(steps[0].Start)
+
+	b := &strings.Builder{}
+	b.WriteString("## Job Timeline\n\n")
+	b.WriteString("```mermaid\ngantt\n")
+	b.WriteString("  dateFormat X\n  axisFormat %H:%M:%S\n")
+	for _, step := range steps {
+		tag := ""
+		if step.Name == bootStepName {
+			tag = "done, "
+		}
+		// Colons and semicolons are separators in Mermaid task definitions
+		name := strings.NewReplacer(":", " ", ";", " ", "#", " ").Replace(step.Name)
+		end := step.End
+		if !end.After(step.Start) {
+			end = step.Start.Add(time.Second)
+		}
+		fmt.Fprintf(b, "  %s :%s%d, %d\n", name, tag, step.Start.Unix(), end.Unix())
+	}
+	b.WriteString("```\n\n")
+
+	rows := [][]string{}
+	for _, step := range steps {
+		share := 0.0
+		if total > 0 {
+			share = float64(step.Duration()) / float64(total) * 100
+		}
+		rows = append(rows, []string{
+			step.Name,
+			step.Start.Format("15:04:05"),
+			step.Duration().String(),
+			fmt.Sprintf("%.1f%%", share),
+			fmt.Sprintf("$%.4f", step.Duration().Minutes()*perMinute),
+		})
+	}
+	rows = append(rows, []string{"Total", steps[0].Start.Format("15:04:05"), total.String(), "100.0%", fmt.Sprintf("$%.4f", total.Minutes()*perMinute)})
+	b.WriteString(utils.RenderMarkdownTable([]string{"step", "started at (UTC)", "duration", "share", "cost"}, rows))
+	b.WriteString("\n")
+	return b.String()
+}
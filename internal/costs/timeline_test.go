@@ -0,0 +1,63 @@
+package costs
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const testWorkerLog = `[2025-04-02 10:00:35Z INFO JobRunner] Starting the job execution context.
+[2025-04-02 10:00:35Z INFO StepsRunner] Processing step: DisplayName='Set up runs-on/action@v2'
+[2025-04-02 10:00:37Z INFO StepsRunner] Step result: Succeeded
+[2025-04-02 10:00:37Z INFO StepsRunner] Processing step: DisplayName='Run actions/checkout@v4'
+[2025-04-02 10:00:42Z INFO StepsRunner] Processing step: DisplayName='Run make test'
+multi-line output without timestamp
+[2025-04-02 10:07:30Z INFO StepsRunner] Step result: Failed
+[2025-04-02 10:07:30Z INFO StepsRunner] Processing step: DisplayName='Post Run runs-on/action@v2'
+`
+
+func TestParseWorkerLog(t *testing.T) {
+	now := time.Date(2025, 4, 2, 10, 7, 45, 0, time.UTC)
+	steps, err := parseWorkerLog(strings.NewReader(testWorkerLog), now)
+	if err != nil {
+		t.Fatalf("parseWorkerLog() error = %v", err)
+	}
+
+	expected := []struct {
+		name     string
+		duration time.Duration
+	}{
+		{"Set up runs-on/action@v2", 2 * time.Second},
+		{"Run actions/checkout@v4", 5 * time.Second},
+		{"Run make test", 6*time.Minute + 48*time.Second},
+		{"Post Run runs-on/action@v2", 15 * time.Second},
+	}
+	if len(steps) != len(expected) {
+		t.Fatalf("got %d steps, want %d: %+v", len(steps), len(expected), steps)
+	}
+	for i, step := range steps {
+		if step.Name != expected[i].name || step.Duration() != expected[i].duration {
+			t.Errorf("step %d = %s (%s), want %s (%s)", i, step.Name, step.Duration(), expected[i].name, expected[i].duration)
+		}
+	}
+}
+
+func TestRenderTimeline(t *testing.T) {
+	start := time.Date(2025, 4, 2, 10, 0, 0, 0, time.UTC)
+	steps := []stepTiming{
+		{Name: bootStepName, Start: start, End: start.Add(time.Minute)},
+		{Name: "Run make: test", Start: start.Add(time.Minute), End: start.Add(4 * time.Minute)},
+	}
+	timeline := renderTimeline(steps, &CostResponseData{DurationMinutes: 4, TotalCost: 0.04})
+
+	for _, expected := range []string{
+		"  Boot and queue :done, 1743588000, 1743588060\n",
+		"  Run make  test :1743588060, 1743588240\n",
+		"| Run make: test | 10:01:00         | 3m0s     | 75.0%  | $0.0300 |",
+		"| Total          | 10:00:00         | 4m0s     | 100.0% | $0.0400 |",
+	} {
+		if !strings.Contains(timeline, expected) {
+			t.Errorf("expected timeline to contain %q, got:\n%s", expected, timeline)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/sethvargo/go-githubactions"
+)
+
+// Version is the version of the action, sent in the User-Agent of outbound requests.
+// It is set at build time with -ldflags "-X github.com/runs-on/action/internal/httpclient.Version=<version>".
+var Version = "dev"
+
+const (
+	DefaultTimeout = 10 * time.Second
+	// The overall deadline of a request, across retries, is a multiple of the per-attempt timeout
+	deadlineFactor = 3
+	maxRetries     = 4
+	initialBackoff = 500 * time.Millisecond
+	maxBackoff     = 5 * time.Second
+)
+
+// UserAgent returns the User-Agent sent by the action.
+func UserAgent() string {
+	return fmt.Sprintf("runs-on-action/%s (+https://github.com/runs-on/action)", Version)
+}
+
+// Client is an HTTP client for all outbound calls of the action. It retries requests with an exponential backoff
+// on connection errors and 5xx responses, within per-attempt and overall deadlines, and honors HTTP_PROXY,
+// HTTPS_PROXY and NO_PROXY.
+type Client struct {
+	HTTPClient *http.Client
+	// Timeout of each attempt, including reading the response body
+	Timeout time.Duration
+	// Deadline of the request, across all attempts
+	Deadline time.Duration
+	// MaxRetries is the number of retries after the first attempt
+	MaxRetries     int
+	InitialBackoff time.Duration
+	action         *githubactions.Action
+}
+
+// New returns a client with the given per-attempt timeout, logging requests as debug messages of the action.
+func New(action *githubactions.Action, timeout time.Duration) *Client {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+
+	return &Client{
+		HTTPClient:     &http.Client{Transport: transport, Timeout: timeout},
+		Timeout:        timeout,
+		Deadline:       deadlineFactor * timeout,
+		MaxRetries:     maxRetries,
+		InitialBackoff: initialBackoff,
+		action:         action,
+	}
+}
+
+// Do sends a request, retrying on connection errors and 5xx responses.
+// Requests with a body are only retried when the body can be replayed (req.GetBody is set).
+func (c *Client) Do(req *http.Request) (resp *http.Response, err error) {
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", UserAgent())
+	}
+
+	// The overall deadline also bounds a single attempt, including reading the response body:
+	// it is released when the body is closed
+	ctx, cancel := context.WithTimeout(req.Context(), c.Deadline)
+	defer func() {
+		if resp != nil {
+			resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+		} else {
+			cancel()
+		}
+	}()
+	req = req.WithContext(ctx)
+
+	start := time.Now()
+	backoff := c.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		resp, err := c.HTTPClient.Do(req)
+		retryable := isRetryable(ctx, resp, err)
+		c.debugf(req, attempt, resp, err, time.Since(start))
+		if !retryable || attempt > c.MaxRetries || time.Since(start)+backoff > c.Deadline {
+			if err != nil && retryable && attempt > 1 {
+				return nil, fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+			}
+			return resp, err
+		}
+		if req.Body != nil && req.GetBody == nil {
+			return resp, err
+		}
+
+		// Drain the failed response so that the connection can be reused
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxBackoff)
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to replay request body: %w", err)
+			}
+			req.Body = body
+		}
+	}
+}
+
+// cancelOnClose releases the context of a request when its response body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// isRetryable tells whether a request failed with a connection error or a server error worth retrying.
+func isRetryable(ctx context.Context, resp *http.Response, err error) bool {
+	if err != nil {
+		// The caller gave up, e.g. its own deadline was exceeded
+		return ctx.Err() == nil && !errors.Is(err, context.Canceled)
+	}
+	return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+}
+
+func (c *Client) debugf(req *http.Request, attempt int, resp *http.Response, err error, elapsed time.Duration) {
+	if c.action == nil {
+		return
+	}
+	if err != nil {
+		c.action.Debugf("HTTP %s %s: attempt %d failed after %s: %v", req.Method, req.URL.Redacted(), attempt, elapsed.Round(time.Millisecond), err)
+		return
+	}
+	c.action.Debugf("HTTP %s %s: attempt %d returned %s after %s", req.Method, req.URL.Redacted(), attempt, resp.Status, elapsed.Round(time.Millisecond))
+}
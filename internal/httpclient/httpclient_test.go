@@ -0,0 +1,131 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestClient() *Client {
+	client := New(nil, time.Second)
+	client.InitialBackoff = time.Millisecond
+	return client
+}
+
+func TestDoRetriesServerErrors(t *testing.T) {
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != "payload" {
+			t.Errorf("attempt %d: body = %q, want payload", attempts.Load()+1, body)
+		}
+		if !strings.HasPrefix(r.UserAgent(), "runs-on-action/dev") {
+			t.Errorf("User-Agent = %q", r.UserAgent())
+		}
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader("payload"))
+	resp, err := newTestClient().Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || attempts.Load() != 3 {
+		t.Errorf("got status %d after %d attempts, want 200 after 3", resp.StatusCode, attempts.Load())
+	}
+	// The body is still readable after Do returns
+	if body, err := io.ReadAll(resp.Body); err != nil || string(body) != "ok" {
+		t.Errorf("body = %q, error = %v, want ok", body, err)
+	}
+}
+
+func TestDoDoesNotRetryClientErrors(t *testing.T) {
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	resp, err := newTestClient().Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest || attempts.Load() != 1 {
+		t.Errorf("got status %d after %d attempts, want 400 after 1", resp.StatusCode, attempts.Load())
+	}
+}
+
+func TestDoGivesUpOnConnectionErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := srv.URL
+	srv.Close()
+
+	client := newTestClient()
+	client.MaxRetries = 2
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	_, err := client.Do(req)
+	if err == nil || !strings.Contains(err.Error(), "giving up after 3 attempts") {
+		t.Errorf("Do() error = %v, want giving up after 3 attempts", err)
+	}
+}
+
+func TestDoPerAttemptTimeout(t *testing.T) {
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	client := New(nil, 50*time.Millisecond)
+	client.InitialBackoff = time.Millisecond
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	resp.Body.Close()
+	if attempts.Load() != 2 {
+		t.Errorf("got %d attempts, want 2", attempts.Load())
+	}
+}
+
+func TestDoOverallDeadline(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	// A single slow attempt is cut at the overall deadline, before its own timeout
+	client := New(nil, time.Second)
+	client.Deadline = 100 * time.Millisecond
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	start := time.Now()
+	_, err := client.Do(req)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Do() error = %v, want deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Do() returned after %s, want about 100ms", elapsed)
+	}
+}
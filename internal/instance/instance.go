@@ -0,0 +1,86 @@
+package instance
+
+import (
+	"context"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+)
+
+// Timeout of the IMDS lookups, kept short so that runners outside of EC2 fall back to the environment quickly.
+const imdsTimeout = 2 * time.Second
+
+// Metadata describes the instance the runner is running on.
+type Metadata struct {
+	InstanceType string
+	Lifecycle    string
+	Region       string
+	Az           string
+	ZoneId       string
+	Arch         string
+}
+
+// Load returns the metadata of the current instance from IMDSv2, falling back to the RUNS_ON_* environment variables
+// for values IMDS cannot provide. The architecture is the one of the running binary.
+func Load(ctx context.Context, client *imds.Client) *Metadata {
+	ctx, cancel := context.WithTimeout(ctx, imdsTimeout)
+	defer cancel()
+
+	metadata := &Metadata{
+		InstanceType: getMetadata(ctx, client, "instance-type"),
+		Lifecycle:    getMetadata(ctx, client, "instance-life-cycle"),
+		Region:       getMetadata(ctx, client, "placement/region"),
+		Az:           getMetadata(ctx, client, "placement/availability-zone"),
+		ZoneId:       getMetadata(ctx, client, "placement/availability-zone-id"),
+		Arch:         goArchToArch(runtime.GOARCH),
+	}
+
+	metadata.InstanceType = withFallback(metadata.InstanceType, os.Getenv("RUNS_ON_INSTANCE_TYPE"))
+	metadata.Lifecycle = withFallback(metadata.Lifecycle, os.Getenv("RUNS_ON_INSTANCE_LIFECYCLE"))
+	metadata.Region = withFallback(metadata.Region, os.Getenv("RUNS_ON_AWS_REGION"))
+	metadata.Az = withFallback(metadata.Az, os.Getenv("RUNS_ON_AWS_AZ"))
+	metadata.Arch = withFallback(metadata.Arch, os.Getenv("RUNS_ON_AGENT_ARCH"))
+	return metadata
+}
+
+// getMetadata returns a metadata value, or an empty string when IMDS is unavailable.
+// Once the context is done, e.g. when not running on EC2, the remaining lookups fail immediately.
+func getMetadata(ctx context.Context, client *imds.Client, path string) string {
+	if ctx.Err() != nil {
+		return ""
+	}
+	output, err := client.GetMetadata(ctx, &imds.GetMetadataInput{Path: path})
+	if err != nil {
+		return ""
+	}
+	defer output.Content.Close()
+
+	value, err := io.ReadAll(output.Content)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(value))
+}
+
+func withFallback(value, fallback string) string {
+	if value != "" {
+		return value
+	}
+	return fallback
+}
+
+// goArchToArch maps Go architectures to the RunsOn ones.
+func goArchToArch(goArch string) string {
+	switch goArch {
+	case "amd64":
+		return "x64"
+	case "arm64":
+		return "arm64"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,45 @@
+package instance
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/runs-on/action/internal/testutil"
+)
+
+func TestLoadFromIMDS(t *testing.T) {
+	t.Setenv("RUNS_ON_INSTANCE_TYPE", "m7i.large")
+	t.Setenv("RUNS_ON_AWS_AZ", "us-east-1a")
+	client := testutil.NewIMDSServer(t, map[string]string{
+		"instance-type":                  "m7g.xlarge",
+		"instance-life-cycle":            "spot",
+		"placement/region":               "us-east-1",
+		"placement/availability-zone":    "us-east-1b",
+		"placement/availability-zone-id": "use1-az4",
+	})
+
+	metadata := Load(context.Background(), client)
+
+	expected := &Metadata{InstanceType: "m7g.xlarge", Lifecycle: "spot", Region: "us-east-1", Az: "us-east-1b", ZoneId: "use1-az4", Arch: goArchToArch(runtime.GOARCH)}
+	if *metadata != *expected {
+		t.Errorf("Load() = %+v, want %+v", metadata, expected)
+	}
+}
+
+func TestLoadFallsBackToEnvironment(t *testing.T) {
+	t.Setenv("RUNS_ON_INSTANCE_TYPE", "m7i.large")
+	t.Setenv("RUNS_ON_INSTANCE_LIFECYCLE", "on-demand")
+	t.Setenv("RUNS_ON_AWS_REGION", "eu-west-1")
+	t.Setenv("RUNS_ON_AWS_AZ", "eu-west-1a")
+	client := testutil.NewIMDSServer(t, map[string]string{
+		"placement/availability-zone-id": "euw1-az1",
+	})
+
+	metadata := Load(context.Background(), client)
+
+	expected := &Metadata{InstanceType: "m7i.large", Lifecycle: "on-demand", Region: "eu-west-1", Az: "eu-west-1a", ZoneId: "euw1-az1", Arch: goArchToArch(runtime.GOARCH)}
+	if *metadata != *expected {
+		t.Errorf("Load() = %+v, want %+v", metadata, expected)
+	}
+}
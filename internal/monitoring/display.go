@@ -2,8 +2,10 @@ package monitoring
 
 import (
 	"fmt"
+	"math"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/sethvargo/go-githubactions"
 )
@@ -88,3 +90,88 @@ func GetCloudWatchLink(action *githubactions.Action) string {
 	return fmt.Sprintf("https://%[1]s.console.aws.amazon.com/cloudwatch/home?region=%[1]s#metricsV2?graph=~()&namespace=~'%[2]s",
 		region, NAMESPACE)
 }
+
+// resampleByTime spreads data points over a fixed number of evenly spaced time buckets between start and end.
+// Each data point covers one period starting at its timestamp, buckets covered by several points are averaged,
+// and buckets not covered by any point are left as gaps (NaN).
+func resampleByTime(timestamps []time.Time, values []float64, start, end time.Time, period time.Duration, width int) []float64 {
+	resampled := make([]float64, width)
+	counts := make([]int, width)
+	bucket := float64(end.Sub(start)) / float64(width)
+	if bucket <= 0 {
+		bucket = float64(period)
+	}
+
+	for i, timestamp := range timestamps {
+		from := float64(timestamp.Sub(start))
+		first := int(math.Floor(from / bucket))
+		last := int(math.Ceil((from+float64(period))/bucket)) - 1
+		for b := max(first, 0); b <= min(last, width-1); b++ {
+			resampled[b] += values[i]
+			counts[b]++
+		}
+	}
+
+	for b := range resampled {
+		if counts[b] == 0 {
+			resampled[b] = math.NaN()
+		} else {
+			resampled[b] /= float64(counts[b])
+		}
+	}
+	return resampled
+}
+
+// elapsedSteps are the candidate intervals between two labels of the time axis
+var elapsedSteps = []time.Duration{
+	10 * time.Second, 15 * time.Second, 30 * time.Second,
+	time.Minute, 2 * time.Minute, 5 * time.Minute, 10 * time.Minute, 15 * time.Minute, 30 * time.Minute,
+	time.Hour, 2 * time.Hour, 4 * time.Hour,
+}
+
+// timeAxis builds a line of elapsed-time labels (e.g. 0m, 5m, 10m) aligned with the columns of an asciigraph chart
+// of the given number of points spanning the given duration. It also returns the column where the plot starts.
+func timeAxis(graph string, points int, span time.Duration) (string, int) {
+	// The plot starts at the y-axis character of the first chart line
+	firstLine := strings.SplitN(graph, "\n", 2)[0]
+	offset := 0
+	for i, r := range []rune(firstLine) {
+		if r == '┤' || r == '┼' {
+			offset = i
+			break
+		}
+	}
+
+	step := elapsedSteps[len(elapsedSteps)-1]
+	for _, candidate := range elapsedSteps {
+		if span/candidate <= 6 {
+			step = candidate
+			break
+		}
+	}
+
+	line := []rune(strings.Repeat(" ", offset+points+8))
+	next := 0
+	for elapsed := time.Duration(0); elapsed <= span; elapsed += step {
+		column := offset
+		if span > 0 && points > 1 {
+			column += int(math.Round(float64(elapsed) / float64(span) * float64(points-1)))
+		}
+		if column < next {
+			continue
+		}
+		label := formatElapsed(elapsed)
+		copy(line[column:], []rune(label))
+		next = column + len(label) + 1
+	}
+
+	return strings.TrimRight(string(line), " "), offset
+}
+
+// formatElapsed renders an elapsed time as minutes when possible (e.g. "5m"), or as a duration otherwise
+func formatElapsed(d time.Duration) string {
+	if d%time.Minute == 0 {
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+	return d.String()
+}
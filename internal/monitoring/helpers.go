@@ -2,10 +2,16 @@ package monitoring
 
 import (
 	"bufio"
+	"fmt"
 	"math"
 	"os"
 	"os/exec"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/runs-on/action/internal/utils"
 )
 
 const DEFAULT_NETWORK_INTERFACE = "enp39s0"
@@ -149,3 +155,129 @@ func sanitizeFloatSeries(data []float64) []float64 {
 
 	return sanitized
 }
+
+// calculatePercentile returns the p-th percentile (0-100) of a slice of floats,
+// interpolating linearly between the closest ranks
+func calculatePercentile(data []float64, p float64) float64 {
+	data = sanitizeFloatSeries(data)
+	if len(data) == 0 {
+		return 0
+	}
+
+	sorted := make([]float64, len(data))
+	copy(sorted, data)
+	sort.Float64s(sorted)
+
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if lower < 0 {
+		lower = 0
+	}
+	if upper >= len(sorted) {
+		upper = len(sorted) - 1
+	}
+
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+// calculateStdDev computes the population standard deviation of a slice of floats
+func calculateStdDev(data []float64) float64 {
+	data = sanitizeFloatSeries(data)
+	if len(data) == 0 {
+		return 0
+	}
+
+	_, _, avg := calculateStats(data)
+	sum := 0.0
+	for _, v := range data {
+		sum += (v - avg) * (v - avg)
+	}
+
+	return math.Sqrt(sum / float64(len(data)))
+}
+
+// timeAboveThreshold returns how long the series stayed strictly above the threshold,
+// assuming each data point covers one sampling period
+func timeAboveThreshold(data []float64, threshold float64, period time.Duration) time.Duration {
+	count := 0
+	for _, v := range sanitizeFloatSeries(data) {
+		if v > threshold {
+			count++
+		}
+	}
+
+	return time.Duration(count) * period
+}
+
+var byteUnits = []string{"B", "KiB", "MiB", "GiB", "TiB"}
+
+// toRates converts per-period counter deltas into per-second rates
+func toRates(data []float64, period time.Duration) []float64 {
+	rates := make([]float64, len(data))
+	for i, v := range data {
+		rates[i] = v / period.Seconds()
+	}
+	return rates
+}
+
+// scaleUnit scales byte based series (Bytes or Bytes/s) to the largest binary unit
+// that keeps the maximum value above 1. Other units are returned unchanged.
+func scaleUnit(data []float64, unit string) ([]float64, string) {
+	base, suffix := unit, ""
+	if strings.HasSuffix(unit, "/s") {
+		base, suffix = strings.TrimSuffix(unit, "/s"), "/s"
+	}
+	if !strings.EqualFold(base, "Bytes") {
+		return data, unit
+	}
+
+	_, max, _ := calculateStats(data)
+	exp := 0
+	for max >= 1024 && exp < len(byteUnits)-1 {
+		max /= 1024
+		exp++
+	}
+
+	factor := math.Pow(1024, float64(exp))
+	scaled := make([]float64, len(data))
+	for i, v := range data {
+		scaled[i] = v / factor
+	}
+	return scaled, byteUnits[exp] + suffix
+}
+
+// formatTotal renders the accumulated value of a counter in its raw unit
+func formatTotal(total float64, unit string) string {
+	switch strings.ToLower(unit) {
+	case "bytes":
+		return utils.FormatBytes(total)
+	case "ms":
+		return (time.Duration(total) * time.Millisecond).Round(time.Second).String()
+	default:
+		return fmt.Sprintf("%.0f %s", total, strings.ToLower(unit))
+	}
+}
+
+// NetworkBytes returns the bytes received and sent by a network interface since boot,
+// which for a RunsOn runner covers the whole job. Only available on Linux.
+func NetworkBytes(networkInterface string) (received, sent float64, err error) {
+	iface := getNetworkInterface(networkInterface)
+	for _, counter := range []struct {
+		name  string
+		value *float64
+	}{
+		{"rx_bytes", &received},
+		{"tx_bytes", &sent},
+	} {
+		raw, err := os.ReadFile(fmt.Sprintf("/sys/class/net/%s/statistics/%s", iface, counter.name))
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to read %s counter of interface %s: %w", counter.name, iface, err)
+		}
+		*counter.value, err = strconv.ParseFloat(strings.TrimSpace(string(raw)), 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to parse %s counter of interface %s: %w", counter.name, iface, err)
+		}
+	}
+	return received, sent, nil
+}
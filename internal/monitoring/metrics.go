@@ -19,6 +19,12 @@ import (
 
 const NAMESPACE = "CWAgent"
 
+// metricPeriod is the CloudWatch query granularity, each data point covers one period
+const metricPeriod = 10 * time.Second
+
+// chartWidth is the number of columns used to plot a metric over time
+const chartWidth = 60
+
 type CloudWatchConfig struct {
 	Metrics MetricsConfig `json:"metrics"`
 	Agent   AgentConfig   `json:"agent"`
@@ -41,10 +47,38 @@ type MetricDataPoint struct {
 }
 
 type MetricSummary struct {
-	Name   string
-	Data   []float64
-	Unit   string
-	Source string // "AWS" or "Custom"
+	Name       string
+	Data       []float64
+	Timestamps []time.Time   // Start of each data point, same length as Data
+	Start      time.Time     // Start of the observed time range (instance launch)
+	Period     time.Duration // Time covered by each data point
+	Unit       string
+	Source     string // "AWS" or "Custom"
+}
+
+// series returns the valid data points along with their timestamps.
+// When timestamps are missing, data points are assumed to be one period apart.
+func (s *MetricSummary) series() ([]time.Time, []float64) {
+	period := s.Period
+	if period <= 0 {
+		period = metricPeriod
+	}
+
+	var timestamps []time.Time
+	var values []float64
+	for i, value := range s.Data {
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			continue
+		}
+		timestamp := s.Start.Add(time.Duration(i) * period)
+		if len(s.Timestamps) == len(s.Data) {
+			timestamp = s.Timestamps[i]
+		}
+		timestamps = append(timestamps, timestamp)
+		values = append(values, value)
+	}
+
+	return timestamps, values
 }
 
 type Measurement struct {
@@ -53,6 +87,13 @@ type Measurement struct {
 	Rename      string
 	Unit        string
 	Aggregation string
+	// Counter marks measurements whose data points are per-period deltas,
+	// which are displayed as per-second rates along with their total.
+	Counter bool
+	// Total describes the accumulated value of a counter, e.g. "received".
+	Total string
+	// Chart is the title of the chart shared by related measurements, empty for a dedicated chart.
+	Chart string
 }
 
 // GetMetricNames returns a list of metric names for a given resource type
@@ -67,6 +108,7 @@ func GetMeasurements(metric string) []Measurement {
 				Rename:      "CPU User",
 				Unit:        "Percent",
 				Aggregation: "Average",
+				Chart:       "CPU",
 			},
 			{
 				Name:        "usage_system",
@@ -74,6 +116,7 @@ func GetMeasurements(metric string) []Measurement {
 				Rename:      "CPU System",
 				Unit:        "Percent",
 				Aggregation: "Average",
+				Chart:       "CPU",
 			},
 		}
 	case "network":
@@ -84,6 +127,9 @@ func GetMeasurements(metric string) []Measurement {
 				Rename:      "Network Received",
 				Unit:        "Bytes",
 				Aggregation: "Sum",
+				Counter:     true,
+				Total:       "received",
+				Chart:       "Network",
 			},
 			{
 				Name:        "bytes_sent",
@@ -91,6 +137,9 @@ func GetMeasurements(metric string) []Measurement {
 				Rename:      "Network Sent",
 				Unit:        "Bytes",
 				Aggregation: "Sum",
+				Counter:     true,
+				Total:       "sent",
+				Chart:       "Network",
 			},
 		}
 	case "memory":
@@ -128,20 +177,28 @@ func GetMeasurements(metric string) []Measurement {
 				Rename:      "Disk IO Time",
 				Unit:        "ms",
 				Aggregation: "Sum",
+				Counter:     true,
+				Total:       "busy",
 			},
 			{
 				Name:        "reads",
 				RealName:    "diskio_reads",
 				Rename:      "Disk Reads",
-				Unit:        "Ops/s",
+				Unit:        "Ops",
 				Aggregation: "Sum",
+				Counter:     true,
+				Total:       "read",
+				Chart:       "Disk Operations",
 			},
 			{
 				Name:        "writes",
 				RealName:    "diskio_writes",
 				Rename:      "Disk Writes",
-				Unit:        "Ops/s",
+				Unit:        "Ops",
 				Aggregation: "Sum",
+				Counter:     true,
+				Total:       "written",
+				Chart:       "Disk Operations",
 			},
 		}
 	default:
@@ -149,7 +206,7 @@ func GetMeasurements(metric string) []Measurement {
 	}
 }
 
-func GenerateMetricsSummary(action *githubactions.Action, metrics []string, formatter, networkInterface, diskDevice string) {
+func GenerateMetricsSummary(action *githubactions.Action, metrics []string, formatter, networkInterface, diskDevice string, thresholds []float64, cpuStacked bool) {
 	if len(metrics) == 0 {
 		return
 	}
@@ -197,6 +254,7 @@ func GenerateMetricsSummary(action *githubactions.Action, metrics []string, form
 		action.Infof("")
 		// Display custom metrics if enabled
 		for _, metricType := range metrics {
+			var groups []*chartGroup
 			measurements := GetMeasurements(metricType)
 			for _, measurement := range measurements {
 				dimensions := []types.Dimension{}
@@ -238,61 +296,284 @@ func GenerateMetricsSummary(action *githubactions.Action, metrics []string, form
 					if metricType == "disk" && variant != "/" && summary == nil {
 						continue
 					}
-					displayMetric(action, measurement.Rename, summary, measurement.Unit, formatter, variant)
+					if measurement.Chart != "" {
+						groups = addToChartGroup(groups, measurement, summary)
+						continue
+					}
+					displayMetric(action, measurement, summary, formatter, variant, thresholds)
 				}
 			}
+			for _, group := range groups {
+				displayMetricGroup(action, group, formatter, thresholds, cpuStacked && metricType == "cpu")
+			}
 		}
 	}
 }
 
-// displayMetric shows a metric in the specified format (sparkline or chart)
-func displayMetric(action *githubactions.Action, name string, summary *MetricSummary, unit string, formatter string, variant string) {
+// chartGroup holds related measurements that are rendered together as one multi-series chart
+type chartGroup struct {
+	title        string
+	measurements []Measurement
+	summaries    []*MetricSummary
+}
+
+// addToChartGroup appends a measurement to the group matching its chart title, creating it if needed
+func addToChartGroup(groups []*chartGroup, measurement Measurement, summary *MetricSummary) []*chartGroup {
+	for _, group := range groups {
+		if group.title == measurement.Chart {
+			group.measurements = append(group.measurements, measurement)
+			group.summaries = append(group.summaries, summary)
+			return groups
+		}
+	}
+	return append(groups, &chartGroup{
+		title:        measurement.Chart,
+		measurements: []Measurement{measurement},
+		summaries:    []*MetricSummary{summary},
+	})
+}
+
+// chartSeries is a metric series ready to be displayed, with counters converted to per-second rates
+type chartSeries struct {
+	measurement Measurement
+	timestamps  []time.Time
+	data        []float64
+	unit        string
+	total       float64
+	start       time.Time
+	period      time.Duration
+}
+
+// prepareSeries extracts the valid data points of a summary, converting counters to per-second rates.
+// It returns nil when there is no valid data.
+func prepareSeries(measurement Measurement, summary *MetricSummary) *chartSeries {
+	timestamps, data := summary.series()
+	if len(data) == 0 {
+		return nil
+	}
+
+	series := &chartSeries{
+		measurement: measurement,
+		timestamps:  timestamps,
+		data:        data,
+		unit:        measurement.Unit,
+		start:       summary.Start,
+		period:      summary.Period,
+	}
+	if series.period <= 0 {
+		series.period = metricPeriod
+	}
+	if series.start.IsZero() || series.start.After(timestamps[0]) {
+		series.start = timestamps[0]
+	}
+
+	if measurement.Counter {
+		for _, v := range data {
+			series.total += v
+		}
+		series.data = toRates(data, series.period)
+		series.unit += "/s"
+	}
+	return series
+}
+
+// scaleSeries scales byte based series to a readable unit shared by all of them
+func scaleSeries(series []*chartSeries) {
+	var all []float64
+	for _, s := range series {
+		all = append(all, s.data...)
+	}
+	scaled, unit := scaleUnit(all, series[0].unit)
+	for _, s := range series {
+		s.data, scaled = scaled[:len(s.data)], scaled[len(s.data):]
+		s.unit = unit
+	}
+}
+
+// displayMetric shows a metric in the specified format (sparkline or chart).
+// Thresholds only apply to percentage based metrics.
+func displayMetric(action *githubactions.Action, measurement Measurement, summary *MetricSummary, formatter string, variant string, thresholds []float64) {
+	name := measurement.Rename
 	if summary == nil {
 		action.Infof("  %-12s ─────────────── (no data yet)", name)
 		return
 	}
-	data := sanitizeFloatSeries(summary.Data)
-	if len(data) == 0 {
+	series := prepareSeries(measurement, summary)
+	if series == nil {
 		action.Infof("  %-12s ─────────────── (no valid data yet)", name)
 		return
 	}
-	min, max, avg := calculateStats(data)
-	if formatter == "chart" {
-		action.Infof("\n📊 %s:", name)
-		caption := fmt.Sprintf("%s (%s)", name, unit)
-		// Build graph options
-		opts := []asciigraph.Option{
-			asciigraph.Height(8),
-			asciigraph.Width(60),
-			asciigraph.Caption(caption),
-			asciigraph.Precision(1),
-		}
+	scaleSeries([]*chartSeries{series})
 
-		// For percentage based metrics, force y-axis from 0 to 100
-		if strings.EqualFold(unit, "percent") {
-			opts = append(opts, asciigraph.LowerBound(0), asciigraph.UpperBound(100))
-		}
-
-		graph := asciigraph.Plot(data, opts...)
-		// Print each line of the graph with proper indentation
-		for _, line := range strings.Split(graph, "\n") {
-			action.Infof("  %s", line)
-		}
-		action.Infof("  Stats: min:%.1f avg:%.1f max:%.1f %s", min, avg, max, unit)
+	if formatter == "chart" {
+		plotChart(action, name, []*chartSeries{series}, false)
+		displaySeriesStats(action, series, "", thresholds)
 	} else {
 		// Use sparkline format
-		sparkline := createSparkline(data)
-		if unit == "ops/s" {
+		min, max, avg := calculateStats(series.data)
+		sparkline := createSparkline(series.data)
+		if strings.EqualFold(series.unit, "ops/s") {
 			action.Infof("  %-12s %s avg:%.0f %s",
-				name, sparkline, avg, unit)
+				name, sparkline, avg, series.unit)
 		} else {
 			action.Infof("  %-12s %s min:%.1f avg:%.1f max:%.1f %s",
-				name, sparkline, min, avg, max, unit)
+				name, sparkline, min, avg, max, series.unit)
+		}
+	}
+	action.Infof("\n")
+}
+
+// displayMetricGroup shows related measurements as one multi-series chart with a legend.
+// When stacked, each series is drawn on top of the previous ones.
+// Groups with less than two series with data fall back to individual charts.
+func displayMetricGroup(action *githubactions.Action, group *chartGroup, formatter string, thresholds []float64, stacked bool) {
+	var series []*chartSeries
+	for i, measurement := range group.measurements {
+		if group.summaries[i] == nil {
+			continue
+		}
+		if s := prepareSeries(measurement, group.summaries[i]); s != nil {
+			series = append(series, s)
+		}
+	}
+
+	if formatter != "chart" || len(series) < 2 {
+		for i, measurement := range group.measurements {
+			displayMetric(action, measurement, group.summaries[i], formatter, "default", thresholds)
+		}
+		return
+	}
+
+	scaleSeries(series)
+	plotChart(action, group.title, series, stacked)
+	// Percentage based series of a group are parts of a whole (CPU user and system),
+	// so thresholds apply to their total rather than to each of them
+	percent := strings.EqualFold(series[0].unit, "percent")
+	seriesThresholds := thresholds
+	if percent {
+		seriesThresholds = nil
+	}
+	for _, s := range series {
+		displaySeriesStats(action, s, s.measurement.Rename, seriesThresholds)
+	}
+	if percent {
+		total := totalSeries(series)
+		for _, threshold := range thresholds {
+			above := timeAboveThreshold(total, threshold, series[0].period)
+			action.Infof("  %s > %g%% for %s", group.title, threshold, above.Round(time.Second))
 		}
 	}
 	action.Infof("\n")
 }
 
+// totalSeries sums the data points of several series sharing the same timestamps
+func totalSeries(series []*chartSeries) []float64 {
+	totals := map[int64]float64{}
+	for _, s := range series {
+		for i, timestamp := range s.timestamps {
+			totals[timestamp.UnixNano()] += s.data[i]
+		}
+	}
+	timestamps := make([]int64, 0, len(totals))
+	for timestamp := range totals {
+		timestamps = append(timestamps, timestamp)
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+	data := make([]float64, len(timestamps))
+	for i, timestamp := range timestamps {
+		data[i] = totals[timestamp]
+	}
+	return data
+}
+
+// seriesColors are the colors used for each series of a multi-series chart
+var seriesColors = []asciigraph.AnsiColor{asciigraph.Blue, asciigraph.Red, asciigraph.Green, asciigraph.Yellow}
+
+// plotChart draws one or more series sharing the same unit on a common time axis
+func plotChart(action *githubactions.Action, title string, series []*chartSeries, stacked bool) {
+	action.Infof("\n📊 %s:", title)
+	unit := series[0].unit
+	caption := fmt.Sprintf("%s (%s)", title, unit)
+	if stacked {
+		caption = fmt.Sprintf("%s (%s, stacked)", title, unit)
+	}
+	// Build graph options
+	opts := []asciigraph.Option{
+		asciigraph.Height(8),
+		asciigraph.Width(chartWidth),
+		asciigraph.Precision(1),
+	}
+	if len(series) > 1 {
+		opts = append(opts, asciigraph.SeriesColors(seriesColors[:len(series)]...))
+	}
+
+	// For percentage based metrics, force y-axis from 0 to 100
+	if strings.EqualFold(unit, "percent") {
+		opts = append(opts, asciigraph.LowerBound(0), asciigraph.UpperBound(100))
+	}
+
+	// Place samples on a common time axis, so that missing samples show up as gaps
+	start, end := series[0].start, series[0].timestamps[0]
+	for _, s := range series {
+		if s.start.Before(start) {
+			start = s.start
+		}
+		if last := s.timestamps[len(s.timestamps)-1].Add(s.period); last.After(end) {
+			end = last
+		}
+	}
+	data := make([][]float64, len(series))
+	for i, s := range series {
+		data[i] = resampleByTime(s.timestamps, s.data, start, end, s.period, chartWidth)
+		if stacked && i > 0 {
+			for j := range data[i] {
+				data[i][j] += data[i-1][j]
+			}
+		}
+	}
+
+	graph := asciigraph.PlotMany(data, opts...)
+	// Print each line of the graph with proper indentation
+	for _, line := range strings.Split(graph, "\n") {
+		action.Infof("  %s", line)
+	}
+	axis, offset := timeAxis(graph, chartWidth, end.Sub(start))
+	action.Infof("  %s", axis)
+	action.Infof("  %s%s", strings.Repeat(" ", offset+max(chartWidth-len(caption), 0)/2), caption)
+
+	if len(series) > 1 {
+		legends := make([]string, len(series))
+		for i, s := range series {
+			legends[i] = fmt.Sprintf("%s■%s %s", seriesColors[i], asciigraph.Default, s.measurement.Rename)
+		}
+		action.Infof("  %s%s", strings.Repeat(" ", offset), strings.Join(legends, "   "))
+	}
+}
+
+// displaySeriesStats prints the statistics of a series, along with the time spent above
+// thresholds for percentage based metrics and the total for counters
+func displaySeriesStats(action *githubactions.Action, series *chartSeries, label string, thresholds []float64) {
+	prefix := "Stats"
+	if label != "" {
+		prefix = fmt.Sprintf("Stats (%s)", label)
+	}
+	data := series.data
+	min, max, avg := calculateStats(data)
+	action.Infof("  %s: min:%.1f avg:%.1f max:%.1f p50:%.1f p95:%.1f p99:%.1f stddev:%.1f %s",
+		prefix, min, avg, max,
+		calculatePercentile(data, 50), calculatePercentile(data, 95), calculatePercentile(data, 99),
+		calculateStdDev(data), series.unit)
+	if strings.EqualFold(series.unit, "percent") {
+		for _, threshold := range thresholds {
+			above := timeAboveThreshold(data, threshold, series.period)
+			action.Infof("  %s > %g%% for %s", series.measurement.Rename, threshold, above.Round(time.Second))
+		}
+	}
+	if series.measurement.Counter {
+		action.Infof("  Total: %s %s", formatTotal(series.total, series.measurement.Unit), series.measurement.Total)
+	}
+}
+
 type MetricsCollector struct {
 	cwClient   *cloudwatch.Client
 	instanceID string
@@ -343,20 +624,27 @@ func (mc *MetricsCollector) GetMetricSummary(metricName, namespace string, aggre
 		return nil
 	}
 
-	// Extract values and calculate stats
-	values := make([]float64, len(data))
-	for i, dp := range data {
-		values[i] = dp.Value
+	// Extract values along with their timestamps
+	values := make([]float64, 0, len(data))
+	timestamps := make([]time.Time, 0, len(data))
+	for _, dp := range data {
+		if math.IsNaN(dp.Value) || math.IsInf(dp.Value, 0) {
+			continue
+		}
+		values = append(values, dp.Value)
+		timestamps = append(timestamps, dp.Timestamp)
 	}
-	values = sanitizeFloatSeries(values)
 	if len(values) == 0 {
 		mc.cache[cacheKey] = nil
 		return nil
 	}
 
 	summary := &MetricSummary{
-		Name: metricName,
-		Data: values,
+		Name:       metricName,
+		Data:       values,
+		Timestamps: timestamps,
+		Start:      startTime,
+		Period:     metricPeriod,
 	}
 
 	// Cache the result
@@ -398,7 +686,7 @@ func (mc *MetricsCollector) getMetricData(metricName, namespace string, aggregat
 							},
 						}...),
 					},
-					Period: aws.Int32(10), // 10 seconds granularity for raw data
+					Period: aws.Int32(int32(metricPeriod.Seconds())), // 10 seconds granularity for raw data
 					Stat:   aws.String(aggregation),
 				},
 				ReturnData: aws.Bool(true),
@@ -441,3 +729,24 @@ func (mc *MetricsCollector) getMetricData(metricName, namespace string, aggregat
 
 	return points, nil
 }
+
+// AverageCPUUsage returns the average total CPU usage (user and system, in percent) since the given time,
+// as collected by the CloudWatch agent.
+func AverageCPUUsage(action *githubactions.Action, startTime time.Time) (float64, error) {
+	collector := NewMetricsCollector(action)
+	if collector == nil {
+		return 0, fmt.Errorf("could not initialize metrics collector")
+	}
+
+	dimensions := []types.Dimension{{Name: aws.String("cpu"), Value: aws.String("cpu-total")}}
+	usage := 0.0
+	for _, measurement := range GetMeasurements("cpu") {
+		summary := collector.GetMetricSummary(measurement.RealName, NAMESPACE, measurement.Aggregation, dimensions, startTime)
+		if summary == nil {
+			return 0, fmt.Errorf("no data for metric %s", measurement.RealName)
+		}
+		_, _, avg := calculateStats(summary.Data)
+		usage += avg
+	}
+	return math.Min(usage, 100), nil
+}
@@ -5,6 +5,7 @@ import (
 	"math"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/sethvargo/go-githubactions"
 )
@@ -13,15 +14,15 @@ func TestDisplayMetricChartSkipsInvalidValues(t *testing.T) {
 	var output bytes.Buffer
 	action := githubactions.New(githubactions.WithWriter(&output))
 
-	displayMetric(action, "CPU System", &MetricSummary{
+	displayMetric(action, Measurement{Rename: "CPU System", Unit: "Percent"}, &MetricSummary{
 		Data: []float64{0, math.NaN(), 50, math.Inf(1), 100},
-	}, "Percent", "chart", "default")
+	}, "chart", "default", nil)
 
 	got := output.String()
 	if !strings.Contains(got, "CPU System") {
 		t.Fatalf("expected metric name in output, got %q", got)
 	}
-	if !strings.Contains(got, "Stats: min:0.0 avg:50.0 max:100.0 Percent") {
+	if !strings.Contains(got, "Stats: min:0.0 avg:50.0 max:100.0 p50:50.0 p95:95.0 p99:99.0 stddev:40.8 Percent") {
 		t.Fatalf("expected sanitized stats in output, got %q", got)
 	}
 }
@@ -30,11 +31,139 @@ func TestDisplayMetricAllInvalidValuesShowsNoValidData(t *testing.T) {
 	var output bytes.Buffer
 	action := githubactions.New(githubactions.WithWriter(&output))
 
-	displayMetric(action, "CPU System", &MetricSummary{
+	displayMetric(action, Measurement{Rename: "CPU System", Unit: "Percent"}, &MetricSummary{
 		Data: []float64{math.NaN(), math.Inf(1), math.Inf(-1)},
-	}, "Percent", "chart", "default")
+	}, "chart", "default", nil)
 
 	if !strings.Contains(output.String(), "(no valid data yet)") {
 		t.Fatalf("expected no-valid-data message, got %q", output.String())
 	}
 }
+
+func TestDisplayMetricChartShowsTimeAboveThreshold(t *testing.T) {
+	var output bytes.Buffer
+	action := githubactions.New(githubactions.WithWriter(&output))
+
+	displayMetric(action, Measurement{Rename: "CPU User", Unit: "Percent"}, &MetricSummary{
+		Data: []float64{10, 95, 97, 50, 92, 20},
+	}, "chart", "default", []float64{90, 96})
+
+	got := output.String()
+	if !strings.Contains(got, "CPU User > 90% for 30s") {
+		t.Fatalf("expected time above 90%% in output, got %q", got)
+	}
+	if !strings.Contains(got, "CPU User > 96% for 10s") {
+		t.Fatalf("expected time above 96%% in output, got %q", got)
+	}
+}
+
+func TestDisplayMetricCounterShowsScaledRatesAndTotal(t *testing.T) {
+	var output bytes.Buffer
+	action := githubactions.New(githubactions.WithWriter(&output))
+
+	displayMetric(action, Measurement{Rename: "Network Received", Unit: "Bytes", Counter: true, Total: "received"}, &MetricSummary{
+		Data:   []float64{10240, 20480},
+		Period: 10 * time.Second,
+	}, "chart", "default", nil)
+
+	got := output.String()
+	if !strings.Contains(got, "Network Received (KiB/s)") {
+		t.Fatalf("expected scaled rate unit in caption, got %q", got)
+	}
+	if !strings.Contains(got, "Stats: min:1.0 avg:1.5 max:2.0") || !strings.Contains(got, "KiB/s") {
+		t.Fatalf("expected per-second rates in stats, got %q", got)
+	}
+	if !strings.Contains(got, "Total: 30.0 KiB received") {
+		t.Fatalf("expected total in output, got %q", got)
+	}
+}
+
+func TestCalculatePercentile(t *testing.T) {
+	data := []float64{5, 1, 4, 2, 3, math.NaN()}
+
+	tests := []struct {
+		p    float64
+		want float64
+	}{
+		{0, 1},
+		{50, 3},
+		{95, 4.8},
+		{100, 5},
+	}
+	for _, tt := range tests {
+		if got := calculatePercentile(data, tt.p); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("calculatePercentile(p%.0f) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestResampleByTimeLeavesGapsForMissingSamples(t *testing.T) {
+	start := time.Date(2025, 6, 30, 14, 0, 0, 0, time.UTC)
+	timestamps := []time.Time{
+		start,
+		start.Add(10 * time.Second),
+		// 20s to 50s missing
+		start.Add(50 * time.Second),
+	}
+
+	got := resampleByTime(timestamps, []float64{1, 2, 6}, start, start.Add(time.Minute), 10*time.Second, 6)
+
+	want := []float64{1, 2, math.NaN(), math.NaN(), math.NaN(), 6}
+	for i := range want {
+		if math.IsNaN(want[i]) != math.IsNaN(got[i]) || (!math.IsNaN(want[i]) && got[i] != want[i]) {
+			t.Fatalf("resampleByTime() = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestDisplayMetricGroupRendersCombinedChartWithLegend(t *testing.T) {
+	var output bytes.Buffer
+	action := githubactions.New(githubactions.WithWriter(&output))
+
+	group := &chartGroup{
+		title:        "CPU",
+		measurements: GetMeasurements("cpu"),
+		summaries: []*MetricSummary{
+			{Data: []float64{10, 20, 30}},
+			{Data: []float64{5, 5, 5}},
+		},
+	}
+	displayMetricGroup(action, group, "chart", nil, true)
+
+	got := output.String()
+	if strings.Count(got, "📊") != 1 {
+		t.Fatalf("expected a single combined chart, got %q", got)
+	}
+	if !strings.Contains(got, "CPU (Percent, stacked)") {
+		t.Fatalf("expected stacked caption, got %q", got)
+	}
+	if !strings.Contains(got, "CPU User") || !strings.Contains(got, "CPU System") {
+		t.Fatalf("expected legend with both series, got %q", got)
+	}
+	if !strings.Contains(got, "Stats (CPU System): min:5.0 avg:5.0 max:5.0") {
+		t.Fatalf("expected unstacked stats per series, got %q", got)
+	}
+}
+
+func TestDisplayMetricGroupShowsTimeAboveThresholdForTotal(t *testing.T) {
+	var output bytes.Buffer
+	action := githubactions.New(githubactions.WithWriter(&output))
+
+	group := &chartGroup{
+		title:        "CPU",
+		measurements: GetMeasurements("cpu"),
+		summaries: []*MetricSummary{
+			{Data: []float64{60, 60, 10, 80}},
+			{Data: []float64{35, 20, 5, 15}},
+		},
+	}
+	displayMetricGroup(action, group, "chart", []float64{90}, false)
+
+	got := output.String()
+	if !strings.Contains(got, "CPU > 90% for 20s") {
+		t.Fatalf("expected time above 90%% for total CPU, got %q", got)
+	}
+	if strings.Contains(got, "CPU User >") || strings.Contains(got, "CPU System >") {
+		t.Fatalf("expected no per-series threshold, got %q", got)
+	}
+}
@@ -0,0 +1,191 @@
+package s3cache
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// resolvePaths expands ~ and makes the cached paths absolute, so that they are restored at the same location.
+func resolvePaths(paths []string) ([]string, error) {
+	resolved := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if path == "~" || strings.HasPrefix(path, "~/") {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil, fmt.Errorf("failed to expand %s: %w", path, err)
+			}
+			path = filepath.Join(home, strings.TrimPrefix(path, "~"))
+		}
+		absolute, err := filepath.Abs(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", path, err)
+		}
+		resolved = append(resolved, absolute)
+	}
+	return resolved, nil
+}
+
+// withinPaths returns true when the path is one of the cached paths, or inside one of them.
+func withinPaths(path string, paths []string) bool {
+	for _, root := range paths {
+		if path == root || strings.HasPrefix(path, root+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeArchive writes the given absolute paths as a zstd-compressed tar archive. Missing paths are skipped.
+func writeArchive(w io.Writer, paths []string) error {
+	encoder, err := zstd.NewWriter(w)
+	if err != nil {
+		return fmt.Errorf("failed to create zstd encoder: %w", err)
+	}
+	tw := tar.NewWriter(encoder)
+
+	for _, root := range paths {
+		if _, err := os.Lstat(root); os.IsNotExist(err) {
+			continue
+		}
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			return addToArchive(tw, path, entry)
+		})
+		if err != nil {
+			encoder.Close()
+			return fmt.Errorf("failed to archive %s: %w", root, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		encoder.Close()
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return encoder.Close()
+}
+
+func addToArchive(tw *tar.Writer, path string, entry fs.DirEntry) error {
+	info, err := entry.Info()
+	if err != nil {
+		return err
+	}
+	link := ""
+	if info.Mode()&fs.ModeSymlink != 0 {
+		if link, err = os.Readlink(path); err != nil {
+			return err
+		}
+	} else if !info.Mode().IsRegular() && !info.IsDir() {
+		// Sockets, pipes and devices are not cached
+		return nil
+	}
+
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	header.Name = filepath.ToSlash(path)
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(tw, file)
+	return err
+}
+
+// extractArchive extracts a zstd-compressed tar archive written by writeArchive.
+// Only entries within the cached paths are extracted, and existing files are overwritten.
+func extractArchive(r io.Reader, paths []string) error {
+	decoder, err := zstd.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("failed to create zstd decoder: %w", err)
+	}
+	defer decoder.Close()
+
+	// Symlinks extracted so far: later entries must not be written through them, outside of the cached paths
+	links := map[string]bool{}
+	tr := tar.NewReader(decoder)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		path := filepath.Clean(filepath.FromSlash(header.Name))
+		if !withinPaths(path, paths) || throughLink(path, links) {
+			return fmt.Errorf("archive entry %s is outside of the cached paths", header.Name)
+		}
+		if err := extractEntry(tr, header, path); err != nil {
+			return fmt.Errorf("failed to extract %s: %w", path, err)
+		}
+		if header.Typeflag == tar.TypeSymlink {
+			links[path] = true
+		}
+	}
+}
+
+// throughLink returns true when one of the parent directories of the path is an extracted symlink.
+func throughLink(path string, links map[string]bool) bool {
+	for dir := filepath.Dir(path); dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		if links[dir] {
+			return true
+		}
+	}
+	return false
+}
+
+func extractEntry(tr *tar.Reader, header *tar.Header, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	switch header.Typeflag {
+	case tar.TypeDir:
+		if err := os.MkdirAll(path, header.FileInfo().Mode().Perm()); err != nil {
+			return err
+		}
+	case tar.TypeSymlink:
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+		return os.Symlink(header.Linkname, path)
+	case tar.TypeReg:
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, header.FileInfo().Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(file, tr); err != nil {
+			file.Close()
+			return err
+		}
+		if err := file.Close(); err != nil {
+			return err
+		}
+	default:
+		return nil
+	}
+	// Keep modification times, which build tools such as cargo rely on to skip up-to-date outputs
+	return os.Chtimes(path, header.ModTime, header.ModTime)
+}
@@ -0,0 +1,208 @@
+package s3cache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/runs-on/action/internal/config"
+	"github.com/runs-on/action/internal/utils"
+	"github.com/sethvargo/go-githubactions"
+)
+
+const (
+	directoryCacheTimeout = 30 * time.Minute
+	// Directory caches are stored under the repository prefix, next to the magic cache entries
+	directoryCachePrefix = "directories/"
+	archiveExtension     = ".tar.zst"
+	// Transfers are split in parts sent in parallel
+	transferPartSize    = 16 * 1024 * 1024
+	transferConcurrency = 8
+	// State saved by the main step, for the post step to skip saving on an exact hit
+	matchedKeyState = "cache_matched_key"
+)
+
+// archiveKey returns the S3 key of the archive of a cache key.
+func archiveKey(repositoryPrefix, key string) string {
+	return repositoryPrefix + directoryCachePrefix + key + archiveExtension
+}
+
+// findArchive returns the S3 key of the archive matching the cache key exactly, or else the most recent archive
+// whose key starts with one of the restore keys, tried in order.
+func findArchive(ctx context.Context, client *s3.Client, bucket, repositoryPrefix, key string, restoreKeys []string) (string, bool, error) {
+	exactKey := archiveKey(repositoryPrefix, key)
+	for i, prefix := range append([]string{key}, restoreKeys...) {
+		entries, err := List(ctx, client, bucket, repositoryPrefix+directoryCachePrefix+prefix)
+		if err != nil {
+			return "", false, err
+		}
+		for _, entry := range entries {
+			if i == 0 && entry.Key != exactKey || !strings.HasSuffix(entry.Key, archiveExtension) {
+				continue
+			}
+			return entry.Key, i == 0, nil
+		}
+	}
+	return "", false, nil
+}
+
+// matchedCacheKey returns the cache key of an archive S3 key.
+func matchedCacheKey(repositoryPrefix, objectKey string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(objectKey, repositoryPrefix+directoryCachePrefix), archiveExtension)
+}
+
+// RunRestore restores the cached directories from the RunsOn S3 cache bucket.
+func RunRestore(action *githubactions.Action, cfg *config.Config) error {
+	bucket := os.Getenv("RUNS_ON_S3_BUCKET_CACHE")
+	if bucket == "" {
+		return fmt.Errorf("RUNS_ON_S3_BUCKET_CACHE environment variable is not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), directoryCacheTimeout)
+	defer cancel()
+	client, err := utils.GetS3ClientFromEC2IMDS(ctx, utils.S3EndpointOptions(cfg.S3Endpoint, cfg.S3PathStyle))
+	if err != nil {
+		return err
+	}
+	return Restore(ctx, action, client, bucket, cfg)
+}
+
+// Restore downloads the archive matching the cache key or restore keys, and extracts it to the cached paths.
+// The cache_hit output is only true for an exact match of the cache key.
+func Restore(ctx context.Context, action *githubactions.Action, client *s3.Client, bucket string, cfg *config.Config) error {
+	action.SetOutput("cache_hit", "false")
+	repositoryPrefix, err := RepositoryPrefix()
+	if err != nil {
+		return err
+	}
+	paths, err := resolvePaths(cfg.CachePaths)
+	if err != nil {
+		return err
+	}
+
+	objectKey, exact, err := findArchive(ctx, client, bucket, repositoryPrefix, cfg.CacheKey, cfg.CacheRestoreKeys)
+	if err != nil {
+		return err
+	}
+	if objectKey == "" {
+		action.Infof("Cache not found for key %s.", cfg.CacheKey)
+		return nil
+	}
+
+	// Parts are downloaded in parallel, so the archive goes through a temporary file before extraction
+	archive, err := os.CreateTemp(os.Getenv("RUNNER_TEMP"), "runs-on-cache-*"+archiveExtension)
+	if err != nil {
+		return fmt.Errorf("failed to create temporary archive: %w", err)
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+
+	start := time.Now()
+	downloader := manager.NewDownloader(client, func(d *manager.Downloader) {
+		d.PartSize = transferPartSize
+		d.Concurrency = transferConcurrency
+	})
+	size, err := downloader.Download(ctx, archive, &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(objectKey)})
+	if err != nil {
+		return fmt.Errorf("failed to download cache archive %s: %w", objectKey, err)
+	}
+	if _, err := archive.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read cache archive: %w", err)
+	}
+	if err := extractArchive(archive, paths); err != nil {
+		return err
+	}
+
+	matchedKey := matchedCacheKey(repositoryPrefix, objectKey)
+	action.SaveState(matchedKeyState, matchedKey)
+	action.SetOutput("cache_hit", fmt.Sprintf("%t", exact))
+	action.SetOutput("cache_matched_key", matchedKey)
+	action.Infof("Cache restored from key %s (%s in %s, %s/s).", matchedKey, utils.FormatBytes(float64(size)), time.Since(start).Round(time.Millisecond), utils.FormatBytes(throughput(size, time.Since(start))))
+	return nil
+}
+
+// RunSave saves the cached directories in the RunsOn S3 cache bucket.
+func RunSave(action *githubactions.Action, cfg *config.Config) error {
+	bucket := os.Getenv("RUNS_ON_S3_BUCKET_CACHE")
+	if bucket == "" {
+		return fmt.Errorf("RUNS_ON_S3_BUCKET_CACHE environment variable is not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), directoryCacheTimeout)
+	defer cancel()
+	client, err := utils.GetS3ClientFromEC2IMDS(ctx, utils.S3EndpointOptions(cfg.S3Endpoint, cfg.S3PathStyle))
+	if err != nil {
+		return err
+	}
+	return Save(ctx, action, client, bucket, cfg, os.Getenv("STATE_"+matchedKeyState))
+}
+
+// Save archives the cached paths, and streams the archive to the bucket under the cache key.
+// Nothing is saved when the main step restored the exact cache key, as cache entries are immutable.
+func Save(ctx context.Context, action *githubactions.Action, client *s3.Client, bucket string, cfg *config.Config, matchedKey string) error {
+	if matchedKey == cfg.CacheKey {
+		action.Infof("Cache hit on key %s, not saving cache.", cfg.CacheKey)
+		return nil
+	}
+	repositoryPrefix, err := RepositoryPrefix()
+	if err != nil {
+		return err
+	}
+	paths, err := resolvePaths(cfg.CachePaths)
+	if err != nil {
+		return err
+	}
+
+	// The archive is compressed while it is uploaded, without a temporary file
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(writeArchive(writer, paths))
+	}()
+	counter := &countingReader{r: reader}
+
+	start := time.Now()
+	uploader := manager.NewUploader(client, func(u *manager.Uploader) {
+		u.PartSize = transferPartSize
+		u.Concurrency = transferConcurrency
+	})
+	objectKey := archiveKey(repositoryPrefix, cfg.CacheKey)
+	_, err = uploader.Upload(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(objectKey),
+		Body:        counter,
+		ContentType: aws.String("application/zstd"),
+	})
+	// Unblock the archive writer if the upload stopped early
+	reader.CloseWithError(err)
+	if err != nil {
+		return fmt.Errorf("failed to upload cache archive %s: %w", objectKey, err)
+	}
+
+	action.Infof("Cache saved with key %s (%s in %s, %s/s).", cfg.CacheKey, utils.FormatBytes(float64(counter.n)), time.Since(start).Round(time.Millisecond), utils.FormatBytes(throughput(counter.n, time.Since(start))))
+	return nil
+}
+
+// countingReader counts the bytes read from the underlying reader.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func throughput(size int64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(size) / elapsed.Seconds()
+}
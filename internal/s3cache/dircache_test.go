@@ -0,0 +1,128 @@
+package s3cache
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/runs-on/action/internal/config"
+	"github.com/sethvargo/go-githubactions"
+)
+
+func TestSaveAndRestore(t *testing.T) {
+	t.Setenv("GITHUB_REPOSITORY", "org/repo")
+	t.Setenv("GITHUB_OUTPUT", filepath.Join(t.TempDir(), "output"))
+	t.Setenv("GITHUB_STATE", filepath.Join(t.TempDir(), "state"))
+	fake, client := newFakeS3(t)
+	action := githubactions.New(githubactions.WithWriter(&bytes.Buffer{}))
+
+	dir := filepath.Join(t.TempDir(), "target")
+	modTime := time.Date(2025, 4, 2, 10, 0, 0, 0, time.UTC)
+	if err := os.MkdirAll(filepath.Join(dir, "debug"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// Random content does not compress, so that the archive is uploaded and downloaded in several parts
+	content := make([]byte, transferPartSize+1024*1024)
+	rand.New(rand.NewSource(1)).Read(content)
+	if err := os.WriteFile(filepath.Join(dir, "debug", "app"), content, 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(filepath.Join(dir, "debug", "app"), modTime, modTime)
+	if err := os.Symlink("debug/app", filepath.Join(dir, "app")); err != nil {
+		t.Fatal(err)
+	}
+
+	saveCfg := &config.Config{CachePaths: []string{dir}, CacheKey: "cargo-linux-abc"}
+	if err := Save(context.Background(), action, client, "bucket", saveCfg, ""); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if keys := fake.keys(); len(keys) != 1 || keys[0] != "cache/org/repo/directories/cargo-linux-abc.tar.zst" {
+		t.Fatalf("saved keys = %v", keys)
+	}
+	if archive := fake.objects["cache/org/repo/directories/cargo-linux-abc.tar.zst"]; len(archive.body) <= transferPartSize {
+		t.Fatalf("archive is %d bytes, want more than a transfer part", len(archive.body))
+	}
+	os.RemoveAll(dir)
+
+	// Falls back to the restore key, as the cache key does not exist
+	restoreCfg := &config.Config{CachePaths: []string{dir}, CacheKey: "cargo-linux-def", CacheRestoreKeys: []string{"npm-", "cargo-linux-"}}
+	if err := Restore(context.Background(), action, client, "bucket", restoreCfg); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	restored, err := os.ReadFile(filepath.Join(dir, "app"))
+	if err != nil || !bytes.Equal(restored, content) {
+		t.Fatalf("restored file through symlink: %d bytes, error = %v, want %d bytes", len(restored), err, len(content))
+	}
+	if info, err := os.Stat(filepath.Join(dir, "debug", "app")); err != nil || !info.ModTime().Equal(modTime) || info.Mode().Perm() != 0755 {
+		t.Errorf("restored file info = %v, error = %v, want mode 0755 and modification time %s", info, err, modTime)
+	}
+	outputs, _ := os.ReadFile(os.Getenv("GITHUB_OUTPUT"))
+	if !strings.Contains(string(outputs), "cargo-linux-abc") {
+		t.Errorf("outputs are missing the matched key:\n%s", outputs)
+	}
+
+	// An exact hit is not saved again
+	if err := Save(context.Background(), action, client, "bucket", saveCfg, "cargo-linux-abc"); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+}
+
+func TestRestoreMiss(t *testing.T) {
+	t.Setenv("GITHUB_REPOSITORY", "org/repo")
+	t.Setenv("GITHUB_OUTPUT", filepath.Join(t.TempDir(), "output"))
+	_, client := newFakeS3(t)
+	var out bytes.Buffer
+	action := githubactions.New(githubactions.WithWriter(&out))
+
+	cfg := &config.Config{CachePaths: []string{t.TempDir()}, CacheKey: "cargo-linux-abc", CacheRestoreKeys: []string{"cargo-"}}
+	if err := Restore(context.Background(), action, client, "bucket", cfg); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "Cache not found for key cargo-linux-abc") {
+		t.Errorf("unexpected log:\n%s", out.String())
+	}
+}
+
+func TestExtractArchiveOutsidePaths(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	tests := []struct {
+		name    string
+		headers []*tar.Header
+	}{
+		{"outside path", []*tar.Header{{Name: filepath.ToSlash(filepath.Join(outside, "file")), Typeflag: tar.TypeReg, Mode: 0644}}},
+		{"parent directory", []*tar.Header{{Name: filepath.ToSlash(root) + "/../file", Typeflag: tar.TypeReg, Mode: 0644}}},
+		{"through symlink", []*tar.Header{
+			{Name: filepath.ToSlash(filepath.Join(root, "link")), Typeflag: tar.TypeSymlink, Linkname: outside},
+			{Name: filepath.ToSlash(filepath.Join(root, "link", "file")), Typeflag: tar.TypeReg, Mode: 0644},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var archive bytes.Buffer
+			encoder, _ := zstd.NewWriter(&archive)
+			tw := tar.NewWriter(encoder)
+			for _, header := range tt.headers {
+				if err := tw.WriteHeader(header); err != nil {
+					t.Fatal(err)
+				}
+			}
+			tw.Close()
+			encoder.Close()
+
+			if err := extractArchive(&archive, []string{root}); err == nil {
+				t.Errorf("extractArchive() error = nil, want an error")
+			}
+			if _, err := os.Stat(filepath.Join(outside, "file")); !os.IsNotExist(err) {
+				t.Errorf("file was written outside of the cached paths")
+			}
+		})
+	}
+}
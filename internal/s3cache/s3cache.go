@@ -0,0 +1,183 @@
+package s3cache
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/runs-on/action/internal/config"
+	"github.com/runs-on/action/internal/utils"
+	"github.com/sethvargo/go-githubactions"
+)
+
+const (
+	adminTimeout = 5 * time.Minute
+	// DeleteObjects accepts at most 1000 keys per request
+	deleteBatchSize = 1000
+	// Number of most recent entries listed in the job summary, which is limited in size
+	summaryMaxEntries = 100
+)
+
+// Entry is a cache entry stored in the RunsOn S3 cache bucket.
+// S3 does not track reads, so the last modification time is the last time the entry was saved.
+type Entry struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// RepositoryPrefix returns the S3 prefix holding the cache entries of the current repository.
+func RepositoryPrefix() (string, error) {
+	repository := os.Getenv("GITHUB_REPOSITORY")
+	if repository == "" {
+		return "", fmt.Errorf("GITHUB_REPOSITORY environment variable is not set")
+	}
+	return "cache/" + repository + "/", nil
+}
+
+// List returns the entries stored under the given prefix, most recent first.
+func List(ctx context.Context, client *s3.Client, bucket, prefix string) ([]Entry, error) {
+	entries := []Entry{}
+	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{Bucket: aws.String(bucket), Prefix: aws.String(prefix)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list cache entries: %w", err)
+		}
+		for _, object := range page.Contents {
+			entries = append(entries, Entry{
+				Key:          aws.ToString(object.Key),
+				Size:         aws.ToInt64(object.Size),
+				LastModified: aws.ToTime(object.LastModified),
+			})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].LastModified.After(entries[j].LastModified) })
+	return entries, nil
+}
+
+// OlderThan returns the entries last saved more than the given age ago.
+func OlderThan(entries []Entry, age time.Duration, now time.Time) []Entry {
+	old := []Entry{}
+	for _, entry := range entries {
+		if now.Sub(entry.LastModified) > age {
+			old = append(old, entry)
+		}
+	}
+	return old
+}
+
+// Purge deletes the given entries, and returns the number of deleted entries.
+func Purge(ctx context.Context, client *s3.Client, bucket string, entries []Entry) (int, error) {
+	deleted := 0
+	for start := 0; start < len(entries); start += deleteBatchSize {
+		batch := entries[start:min(start+deleteBatchSize, len(entries))]
+		objects := make([]types.ObjectIdentifier, 0, len(batch))
+		for _, entry := range batch {
+			objects = append(objects, types.ObjectIdentifier{Key: aws.String(entry.Key)})
+		}
+		output, err := client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(bucket),
+			Delete: &types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete cache entries: %w", err)
+		}
+		deleted += len(batch) - len(output.Errors)
+		if len(output.Errors) > 0 {
+			return deleted, fmt.Errorf("failed to delete %d cache entries, e.g. %s: %s", len(output.Errors), aws.ToString(output.Errors[0].Key), aws.ToString(output.Errors[0].Message))
+		}
+	}
+	return deleted, nil
+}
+
+// RunAdmin lists or purges the cache entries of the current repository, as selected by the cache_admin inputs.
+func RunAdmin(action *githubactions.Action, cfg *config.Config) error {
+	bucket := os.Getenv("RUNS_ON_S3_BUCKET_CACHE")
+	if bucket == "" {
+		return fmt.Errorf("RUNS_ON_S3_BUCKET_CACHE environment variable is not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+	client, err := utils.GetS3ClientFromEC2IMDS(ctx, utils.S3EndpointOptions(cfg.S3Endpoint, cfg.S3PathStyle))
+	if err != nil {
+		return err
+	}
+	return Admin(ctx, action, client, bucket, cfg)
+}
+
+// Admin lists the cache entries of the current repository matching the prefix and age inputs, then purges them if requested.
+func Admin(ctx context.Context, action *githubactions.Action, client *s3.Client, bucket string, cfg *config.Config) error {
+	repositoryPrefix, err := RepositoryPrefix()
+	if err != nil {
+		return err
+	}
+	// Keep the prefix within the repository
+	if strings.Contains(cfg.CacheAdminPrefix, "..") {
+		return fmt.Errorf("invalid cache key prefix %q", cfg.CacheAdminPrefix)
+	}
+	if cfg.CacheAdminAction == config.CacheAdminActionPurge && cfg.CacheAdminPrefix == "" && cfg.CacheAdminOlderThan == 0 {
+		return fmt.Errorf("purging requires a 'cache_admin_prefix' or 'cache_admin_older_than' input, refusing to purge all the cache entries of the repository")
+	}
+
+	entries, err := List(ctx, client, bucket, repositoryPrefix+cfg.CacheAdminPrefix)
+	if err != nil {
+		return err
+	}
+	if cfg.CacheAdminOlderThan > 0 {
+		entries = OlderThan(entries, cfg.CacheAdminOlderThan, time.Now())
+	}
+
+	totalSize := int64(0)
+	for _, entry := range entries {
+		totalSize += entry.Size
+	}
+	action.SetOutput("cache_entries", fmt.Sprintf("%d", len(entries)))
+	action.SetOutput("cache_bytes", fmt.Sprintf("%d", totalSize))
+
+	summary := renderEntries(entries, repositoryPrefix, totalSize, cfg.CacheAdminAction == config.CacheAdminActionPurge)
+	fmt.Print(summary)
+	action.AddStepSummary(summary)
+
+	if cfg.CacheAdminAction != config.CacheAdminActionPurge {
+		return nil
+	}
+	deleted, err := Purge(ctx, client, bucket, entries)
+	action.Infof("Purged %d cache entries (%s) from s3://%s/%s%s", deleted, utils.FormatBytes(float64(totalSize)), bucket, repositoryPrefix, cfg.CacheAdminPrefix)
+	return err
+}
+
+// renderEntries renders the summary of the listed or purged entries, with only the most recent ones in the table.
+func renderEntries(entries []Entry, repositoryPrefix string, totalSize int64, purge bool) string {
+	rows := [][]string{}
+	for _, entry := range entries[:min(len(entries), summaryMaxEntries)] {
+		rows = append(rows, []string{
+			strings.TrimPrefix(entry.Key, repositoryPrefix),
+			utils.FormatBytes(float64(entry.Size)),
+			entry.LastModified.UTC().Format(time.RFC3339),
+		})
+	}
+
+	b := &strings.Builder{}
+	if purge {
+		b.WriteString("## Purged Cache Entries\n\n")
+	} else {
+		b.WriteString("## Cache Entries\n\n")
+	}
+	fmt.Fprintf(b, "%d entries (%s) under `%s`.\n\n", len(entries), utils.FormatBytes(float64(totalSize)), repositoryPrefix)
+	if len(rows) > 0 {
+		b.WriteString(utils.RenderMarkdownTable([]string{"key", "size", "last saved (UTC)"}, rows))
+		b.WriteString("\n")
+	}
+	if more := len(entries) - len(rows); more > 0 {
+		fmt.Fprintf(b, "%d more entries, not listed.\n", more)
+	}
+	return b.String()
+}
@@ -0,0 +1,237 @@
+package s3cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/runs-on/action/internal/config"
+	"github.com/runs-on/action/internal/utils"
+	"github.com/sethvargo/go-githubactions"
+)
+
+type fakeObject struct {
+	body         []byte
+	lastModified time.Time
+}
+
+// fakeS3 is a minimal S3-compatible server, with path-style addressing and a single bucket.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string]fakeObject
+	// Parts of multipart uploads in progress, by upload id and part number
+	uploads map[string]map[int][]byte
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *s3.Client) {
+	t.Helper()
+	fake := &fakeS3{objects: map[string]fakeObject{}, uploads: map[string]map[int][]byte{}}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	client := s3.New(s3.Options{
+		Region:      "us-east-1",
+		Credentials: aws.AnonymousCredentials{},
+	}, utils.S3EndpointOptions(srv.URL, true))
+	return fake, client
+}
+
+func (f *fakeS3) put(key string, body []byte, lastModified time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.objects[key] = fakeObject{body: body, lastModified: lastModified}
+}
+
+func (f *fakeS3) keys() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	keys := []string{}
+	for key := range f.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	query := r.URL.Query()
+
+	switch {
+	case r.Method == http.MethodGet && key == "" && query.Get("list-type") == "2":
+		f.list(w, query.Get("prefix"))
+	case r.Method == http.MethodPost && key == "" && query.Has("delete"):
+		var request struct {
+			Objects []struct {
+				Key string `xml:"Key"`
+			} `xml:"Object"`
+		}
+		if err := xml.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, object := range request.Objects {
+			delete(f.objects, object.Key)
+		}
+		fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><DeleteResult></DeleteResult>`)
+	case r.Method == http.MethodPost && key != "" && query.Has("uploads"):
+		uploadID := fmt.Sprintf("upload-%d", len(f.uploads)+1)
+		f.uploads[uploadID] = map[int][]byte{}
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><InitiateMultipartUploadResult><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>`, key, uploadID)
+	case r.Method == http.MethodPut && key != "" && query.Has("uploadId"):
+		var partNumber int
+		fmt.Sscanf(query.Get("partNumber"), "%d", &partNumber)
+		body, _ := io.ReadAll(r.Body)
+		f.uploads[query.Get("uploadId")][partNumber] = body
+		w.Header().Set("ETag", fmt.Sprintf(`"part-%d"`, partNumber))
+	case r.Method == http.MethodPost && key != "" && query.Has("uploadId"):
+		parts := f.uploads[query.Get("uploadId")]
+		body := []byte{}
+		for i := 1; i <= len(parts); i++ {
+			body = append(body, parts[i]...)
+		}
+		delete(f.uploads, query.Get("uploadId"))
+		f.objects[key] = fakeObject{body: body, lastModified: time.Now().UTC()}
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><CompleteMultipartUploadResult><Key>%s</Key><ETag>"complete"</ETag></CompleteMultipartUploadResult>`, key)
+	case r.Method == http.MethodPut && key != "":
+		body, _ := io.ReadAll(r.Body)
+		f.objects[key] = fakeObject{body: body, lastModified: time.Now().UTC()}
+	case r.Method == http.MethodGet && key != "":
+		object, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchKey</Code></Error>`)
+			return
+		}
+		// Ranged requests are used by parallel downloads
+		var start, end int
+		if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end); err == nil {
+			end = min(end, len(object.body)-1)
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(object.body)))
+			w.Header().Set("Content-Length", fmt.Sprintf("%d", end-start+1))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(object.body[start : end+1])
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(object.body)))
+		w.Write(object.body)
+	default:
+		http.Error(w, "not implemented", http.StatusNotImplemented)
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, prefix string) {
+	b := &strings.Builder{}
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?><ListBucketResult><IsTruncated>false</IsTruncated>`)
+	for key, object := range f.objects {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		fmt.Fprintf(b, "<Contents><Key>%s</Key><LastModified>%s</LastModified><Size>%d</Size></Contents>", key, object.lastModified.Format(time.RFC3339), len(object.body))
+	}
+	b.WriteString("</ListBucketResult>")
+	fmt.Fprint(w, b.String())
+}
+
+func TestAdmin(t *testing.T) {
+	t.Setenv("GITHUB_REPOSITORY", "org/repo")
+	t.Setenv("GITHUB_OUTPUT", t.TempDir()+"/output")
+	t.Setenv("GITHUB_STEP_SUMMARY", t.TempDir()+"/summary.md")
+	now := time.Now().UTC()
+
+	tests := []struct {
+		name      string
+		cfg       *config.Config
+		wantKeys  []string
+		wantError bool
+	}{
+		{
+			name:     "list",
+			cfg:      &config.Config{CacheAdminAction: config.CacheAdminActionList},
+			wantKeys: []string{"cache/org/other/key", "cache/org/repo/cargo-linux-new", "cache/org/repo/cargo-linux-old", "cache/org/repo/node-linux-old"},
+		},
+		{
+			name:     "purge by prefix",
+			cfg:      &config.Config{CacheAdminAction: config.CacheAdminActionPurge, CacheAdminPrefix: "cargo-"},
+			wantKeys: []string{"cache/org/other/key", "cache/org/repo/node-linux-old"},
+		},
+		{
+			name:     "purge by age",
+			cfg:      &config.Config{CacheAdminAction: config.CacheAdminActionPurge, CacheAdminOlderThan: 7 * 24 * time.Hour},
+			wantKeys: []string{"cache/org/other/key", "cache/org/repo/cargo-linux-new"},
+		},
+		{
+			name:      "purge everything",
+			cfg:       &config.Config{CacheAdminAction: config.CacheAdminActionPurge},
+			wantKeys:  []string{"cache/org/other/key", "cache/org/repo/cargo-linux-new", "cache/org/repo/cargo-linux-old", "cache/org/repo/node-linux-old"},
+			wantError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, client := newFakeS3(t)
+			fake.put("cache/org/repo/cargo-linux-new", make([]byte, 2048), now.Add(-time.Hour))
+			fake.put("cache/org/repo/cargo-linux-old", make([]byte, 1024), now.Add(-30*24*time.Hour))
+			fake.put("cache/org/repo/node-linux-old", make([]byte, 512), now.Add(-10*24*time.Hour))
+			fake.put("cache/org/other/key", make([]byte, 512), now.Add(-30*24*time.Hour))
+
+			var out bytes.Buffer
+			action := githubactions.New(githubactions.WithWriter(&out))
+			err := Admin(context.Background(), action, client, "bucket", tt.cfg)
+			if (err != nil) != tt.wantError {
+				t.Fatalf("Admin() error = %v, wantError %t", err, tt.wantError)
+			}
+			if got := strings.Join(fake.keys(), ","); got != strings.Join(tt.wantKeys, ",") {
+				t.Errorf("remaining keys = %s, want %s", got, strings.Join(tt.wantKeys, ","))
+			}
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	fake, client := newFakeS3(t)
+	now := time.Now().UTC().Truncate(time.Second)
+	fake.put("cache/org/repo/old", make([]byte, 10), now.Add(-time.Hour))
+	fake.put("cache/org/repo/new", make([]byte, 20), now)
+
+	entries, err := List(context.Background(), client, "bucket", "cache/org/repo/")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(entries) != 2 || entries[0].Key != "cache/org/repo/new" || entries[0].Size != 20 || !entries[0].LastModified.Equal(now) {
+		t.Errorf("List() = %+v, want the most recent entry first", entries)
+	}
+	if summary := renderEntries(entries, "cache/org/repo/", 30, false); !strings.Contains(summary, "| new | 20.0 B |") {
+		t.Errorf("renderEntries() is missing the entry key relative to the repository prefix:\n%s", summary)
+	}
+}
+
+func TestRenderEntriesCapsRows(t *testing.T) {
+	now := time.Now()
+	entries := []Entry{}
+	for i := range summaryMaxEntries + 20 {
+		entries = append(entries, Entry{Key: fmt.Sprintf("cache/org/repo/key-%03d", i), Size: 10, LastModified: now.Add(-time.Duration(i) * time.Minute)})
+	}
+
+	summary := renderEntries(entries, "cache/org/repo/", 1200, false)
+	for _, want := range []string{"120 entries (1.2 KiB)", "| key-099 |", "20 more entries, not listed."} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("renderEntries() is missing %q:\n%s", want, summary)
+		}
+	}
+	if strings.Contains(summary, "key-100") {
+		t.Errorf("renderEntries() lists more than %d entries:\n%s", summaryMaxEntries, summary)
+	}
+}
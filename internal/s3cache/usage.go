@@ -0,0 +1,222 @@
+package s3cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/runs-on/action/internal/config"
+	"github.com/runs-on/action/internal/utils"
+	"github.com/sethvargo/go-githubactions"
+)
+
+const (
+	usageTimeout = 5 * time.Minute
+	// Usage reports are stored in the RunsOn S3 cache bucket, under <prefix>/<repository>.json, to compute the growth between reports
+	usageReportsPrefix = "cache/runs-on/usage"
+	// Prefix of the cost records saved for the workflow run cost rollup
+	costRecordsPrefix = "cache/runs-on/costs"
+)
+
+// PrefixUsage is the number of objects and bytes stored under a prefix of the cache bucket.
+type PrefixUsage struct {
+	Name    string `json:"name"`
+	Prefix  string `json:"prefix"`
+	Objects int64  `json:"objects"`
+	Bytes   int64  `json:"bytes"`
+}
+
+// UsageReport is the cache usage of a repository at a given time.
+type UsageReport struct {
+	Repository  string        `json:"repository"`
+	GeneratedAt time.Time     `json:"generatedAt"`
+	Prefixes    []PrefixUsage `json:"prefixes"`
+	Objects     int64         `json:"objects"`
+	Bytes       int64         `json:"bytes"`
+}
+
+// usagePrefixes returns the prefixes of the cache bucket used by a repository.
+// The sccache prefix is shared by all the repositories using the bucket.
+func usagePrefixes(repository string) []PrefixUsage {
+	return []PrefixUsage{
+		{Name: "Magic cache and directory caches", Prefix: "cache/" + repository + "/"},
+		{Name: "sccache (shared)", Prefix: "cache/sccache/"},
+		{Name: "Cost records", Prefix: costRecordsPrefix + "/" + repository + "/"},
+	}
+}
+
+// measure totals the objects and bytes stored under a prefix.
+func measure(ctx context.Context, client *s3.Client, bucket string, usage *PrefixUsage) error {
+	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{Bucket: aws.String(bucket), Prefix: aws.String(usage.Prefix)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list %s: %w", usage.Prefix, err)
+		}
+		for _, object := range page.Contents {
+			usage.Objects++
+			usage.Bytes += aws.ToInt64(object.Size)
+		}
+	}
+	return nil
+}
+
+func usageReportKey(repository string) string {
+	return usageReportsPrefix + "/" + repository + ".json"
+}
+
+// loadUsageReport reads the previous usage report of a repository, if any.
+func loadUsageReport(ctx context.Context, client *s3.Client, bucket, repository string) (*UsageReport, error) {
+	object, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(usageReportKey(repository))})
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to download previous usage report: %w", err)
+	}
+	defer object.Body.Close()
+
+	raw, err := io.ReadAll(object.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read previous usage report: %w", err)
+	}
+	report := &UsageReport{}
+	if err := json.Unmarshal(raw, report); err != nil {
+		return nil, fmt.Errorf("failed to parse previous usage report: %w", err)
+	}
+	return report, nil
+}
+
+func saveUsageReport(ctx context.Context, client *s3.Client, bucket string, report *UsageReport) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to marshal usage report: %w", err)
+	}
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(usageReportKey(report.Repository)),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/json"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload usage report: %w", err)
+	}
+	return nil
+}
+
+// RunUsage reports the cache usage of the current repository in the RunsOn S3 cache bucket.
+func RunUsage(action *githubactions.Action, cfg *config.Config) error {
+	bucket := os.Getenv("RUNS_ON_S3_BUCKET_CACHE")
+	if bucket == "" {
+		return fmt.Errorf("RUNS_ON_S3_BUCKET_CACHE environment variable is not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), usageTimeout)
+	defer cancel()
+	client, err := utils.GetS3ClientFromEC2IMDS(ctx, utils.S3EndpointOptions(cfg.S3Endpoint, cfg.S3PathStyle))
+	if err != nil {
+		return err
+	}
+	return Usage(ctx, action, client, bucket, cfg, time.Now())
+}
+
+// Usage totals the objects and bytes under the prefixes of the current repository, with the growth since the previous report,
+// and warns when the total goes over the cache quota. The report is then saved for the next one.
+func Usage(ctx context.Context, action *githubactions.Action, client *s3.Client, bucket string, cfg *config.Config, now time.Time) error {
+	repository := os.Getenv("GITHUB_REPOSITORY")
+	if repository == "" {
+		return fmt.Errorf("GITHUB_REPOSITORY environment variable is not set")
+	}
+
+	report := &UsageReport{Repository: repository, GeneratedAt: now.UTC(), Prefixes: usagePrefixes(repository)}
+	for i := range report.Prefixes {
+		if err := measure(ctx, client, bucket, &report.Prefixes[i]); err != nil {
+			return err
+		}
+		report.Objects += report.Prefixes[i].Objects
+		report.Bytes += report.Prefixes[i].Bytes
+	}
+
+	previous, err := loadUsageReport(ctx, client, bucket, repository)
+	if err != nil {
+		// Growth is only informative, the report is still useful without it
+		action.Warningf("%v", err)
+	}
+
+	action.SetOutput("cache_usage_objects", fmt.Sprintf("%d", report.Objects))
+	action.SetOutput("cache_usage_bytes", fmt.Sprintf("%d", report.Bytes))
+
+	summary := renderUsage(report, previous, cfg.CacheQuota)
+	fmt.Print(summary)
+	action.AddStepSummary(summary)
+
+	if cfg.CacheQuota > 0 && report.Bytes > cfg.CacheQuota {
+		action.WithFieldsMap(map[string]string{"title": "Cache quota exceeded"}).Warningf("Cache usage of %s is %s, over the quota of %s.", repository, utils.FormatBytes(float64(report.Bytes)), utils.FormatBytes(float64(cfg.CacheQuota)))
+	}
+
+	return saveUsageReport(ctx, client, bucket, report)
+}
+
+// growth renders the difference between the current and previous values.
+func growth(current, previous int64, format func(int64) string) string {
+	difference := current - previous
+	sign := "+"
+	if difference < 0 {
+		sign = "-"
+		difference = -difference
+	}
+	return sign + format(difference)
+}
+
+func renderUsage(report *UsageReport, previous *UsageReport, quota int64) string {
+	formatBytes := func(v int64) string { return utils.FormatBytes(float64(v)) }
+	formatCount := func(v int64) string { return fmt.Sprintf("%d", v) }
+
+	previousPrefixes := map[string]PrefixUsage{}
+	if previous != nil {
+		for _, usage := range previous.Prefixes {
+			previousPrefixes[usage.Prefix] = usage
+		}
+	}
+
+	headers := []string{"prefix", "objects", "size"}
+	if previous != nil {
+		headers = append(headers, "growth")
+	}
+	rows := [][]string{}
+	for _, usage := range report.Prefixes {
+		row := []string{fmt.Sprintf("%s (`%s`)", usage.Name, usage.Prefix), formatCount(usage.Objects), formatBytes(usage.Bytes)}
+		if previous != nil {
+			before := previousPrefixes[usage.Prefix]
+			row = append(row, fmt.Sprintf("%s objects, %s", growth(usage.Objects, before.Objects, formatCount), growth(usage.Bytes, before.Bytes, formatBytes)))
+		}
+		rows = append(rows, row)
+	}
+	total := []string{"Total", formatCount(report.Objects), formatBytes(report.Bytes)}
+	if previous != nil {
+		total = append(total, fmt.Sprintf("%s objects, %s", growth(report.Objects, previous.Objects, formatCount), growth(report.Bytes, previous.Bytes, formatBytes)))
+	}
+	rows = append(rows, total)
+
+	b := &strings.Builder{}
+	b.WriteString("## Cache Usage\n\n")
+	b.WriteString(utils.RenderMarkdownTable(headers, rows))
+	b.WriteString("\n")
+	if previous != nil {
+		fmt.Fprintf(b, "Growth since the previous report, %s ago.\n", report.GeneratedAt.Sub(previous.GeneratedAt).Round(time.Minute))
+	}
+	if quota > 0 {
+		fmt.Fprintf(b, "Quota: %s (%.1f%% used).\n", utils.FormatBytes(float64(quota)), float64(report.Bytes)/float64(quota)*100)
+	}
+	return b.String()
+}
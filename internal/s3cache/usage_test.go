@@ -0,0 +1,63 @@
+package s3cache
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/runs-on/action/internal/config"
+	"github.com/sethvargo/go-githubactions"
+)
+
+func TestUsage(t *testing.T) {
+	t.Setenv("GITHUB_REPOSITORY", "org/repo")
+	t.Setenv("GITHUB_OUTPUT", filepath.Join(t.TempDir(), "output"))
+	fake, client := newFakeS3(t)
+	now := time.Date(2025, 4, 2, 10, 0, 0, 0, time.UTC)
+	fake.put("cache/org/repo/directories/cargo.tar.zst", make([]byte, 3*1024), now)
+	fake.put("cache/org/repo/magic/entry", make([]byte, 1024), now)
+	fake.put("cache/sccache/abc", make([]byte, 512), now)
+	fake.put("cache/org/other/entry", make([]byte, 4096), now)
+	cfg := &config.Config{CacheQuota: 5 * 1024}
+
+	// First report, without growth
+	var out bytes.Buffer
+	summaryFile := filepath.Join(t.TempDir(), "summary.md")
+	t.Setenv("GITHUB_STEP_SUMMARY", summaryFile)
+	action := githubactions.New(githubactions.WithWriter(&out))
+	if err := Usage(context.Background(), action, client, "bucket", cfg, now); err != nil {
+		t.Fatalf("Usage() error = %v", err)
+	}
+	summary, _ := os.ReadFile(summaryFile)
+	for _, want := range []string{"| Magic cache and directory caches (`cache/org/repo/`) | 2       | 4.0 KiB |", "| Total                                                | 3       | 4.5 KiB |", "Quota: 5.0 KiB (90.0% used)."} {
+		if !strings.Contains(string(summary), want) {
+			t.Errorf("summary is missing %q:\n%s", want, summary)
+		}
+	}
+	if strings.Contains(out.String(), "Cache quota exceeded") || strings.Contains(out.String(), "previous usage report") || strings.Contains(string(summary), "growth") {
+		t.Errorf("unexpected quota warning or growth:\n%s\n%s", out.String(), summary)
+	}
+
+	// Second report, with growth over the quota
+	fake.put("cache/org/repo/directories/node.tar.zst", make([]byte, 2*1024), now)
+	fake.put("cache/sccache/abc", make([]byte, 0), now)
+	out.Reset()
+	summaryFile = filepath.Join(t.TempDir(), "summary.md")
+	t.Setenv("GITHUB_STEP_SUMMARY", summaryFile)
+	if err := Usage(context.Background(), action, client, "bucket", cfg, now.Add(24*time.Hour)); err != nil {
+		t.Fatalf("Usage() error = %v", err)
+	}
+	summary, _ = os.ReadFile(summaryFile)
+	for _, want := range []string{"+1 objects, +2.0 KiB", "+0 objects, -512.0 B", "+1 objects, +1.5 KiB", "Growth since the previous report, 24h0m0s ago."} {
+		if !strings.Contains(string(summary), want) {
+			t.Errorf("summary is missing %q:\n%s", want, summary)
+		}
+	}
+	if !strings.Contains(out.String(), "title=Cache quota exceeded") {
+		t.Errorf("missing quota warning:\n%s", out.String())
+	}
+}
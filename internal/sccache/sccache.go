@@ -2,13 +2,15 @@ package sccache
 
 import (
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/sethvargo/go-githubactions"
 )
 
 // ConfigureSccache configures sccache with the appropriate backend.
-// Currently only supports "s3" backend for RunsOn S3 cache bucket.
-func ConfigureSccache(action *githubactions.Action, backend string) error {
+// Currently only supports "s3" backend for RunsOn S3 cache bucket, optionally behind a custom S3 endpoint.
+func ConfigureSccache(action *githubactions.Action, backend string, s3Endpoint string, s3PathStyle bool) error {
 	if backend != "s3" {
 		action.Warningf("Unsupported sccache backend: %s. Only 's3' is currently supported.", backend)
 		return nil
@@ -37,9 +39,21 @@ func ConfigureSccache(action *githubactions.Action, backend string) error {
 		"RUSTC_WRAPPER":         "sccache",
 	}
 
+	// sccache takes the endpoint without its scheme, and whether to use TLS separately
+	if s3Endpoint != "" {
+		host, insecure := strings.CutPrefix(s3Endpoint, "http://")
+		host = strings.TrimPrefix(host, "https://")
+		envVars["SCCACHE_ENDPOINT"] = host
+		envVars["SCCACHE_S3_USE_SSL"] = strconv.FormatBool(!insecure)
+		envVars["SCCACHE_S3_ENABLE_VIRTUAL_HOST_STYLE"] = strconv.FormatBool(!s3PathStyle)
+	}
+
 	action.Infof("Configuring sccache with S3 backend...")
 	action.Infof("Using bucket: %s", bucket)
 	action.Infof("Using region: %s", region)
+	if s3Endpoint != "" {
+		action.Infof("Using endpoint: %s", s3Endpoint)
+	}
 
 	for key, value := range envVars {
 		action.SetEnv(key, value)
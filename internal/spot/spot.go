@@ -0,0 +1,244 @@
+package spot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/sethvargo/go-githubactions"
+)
+
+const (
+	// https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/spot-instance-termination-notices.html
+	instanceActionPath = "spot/instance-action"
+	// https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/rebalance-recommendations.html
+	rebalancePath = "events/recommendations/rebalance"
+
+	hookTimeout = 90 * time.Second
+)
+
+// Notice kinds
+const (
+	KindInterruption = "interruption"
+	KindRebalance    = "rebalance"
+)
+
+// Notice is a spot interruption notice or rebalance recommendation found in IMDS.
+// It is persisted as JSON in the marker file.
+type Notice struct {
+	Kind       string    `json:"kind"`
+	Action     string    `json:"action,omitempty"` // terminate, stop or hibernate, for interruptions
+	Time       string    `json:"time"`             // time of the interruption, or of the recommendation
+	DetectedAt time.Time `json:"detectedAt"`
+}
+
+// StartWatcher spawns a detached copy of the action binary with the --spot-watcher flag,
+// so that IMDS keeps being watched after the main step has finished.
+// The watcher output goes to a log file next to the marker file.
+func StartWatcher(action *githubactions.Action, markerFile string) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find action binary: %w", err)
+	}
+
+	logFile, err := os.OpenFile(markerFile+".log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create spot watcher log file: %w", err)
+	}
+	defer logFile.Close()
+
+	// Stdio must not be inherited, otherwise the main step would wait for the watcher to exit
+	cmd := exec.Command(executable, "--spot-watcher")
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start spot watcher: %w", err)
+	}
+	if err := os.WriteFile(markerFile+".pid", []byte(strconv.Itoa(cmd.Process.Pid)), 0644); err != nil {
+		action.Warningf("Failed to write spot watcher pid file: %v", err)
+	}
+
+	action.Infof("Spot interruption watcher started (pid %d, log: %s)", cmd.Process.Pid, logFile.Name())
+	return cmd.Process.Release()
+}
+
+// StopWatcher stops the background watcher started by StartWatcher, if any.
+func StopWatcher(markerFile string) {
+	raw, err := os.ReadFile(markerFile + ".pid")
+	if err != nil {
+		return
+	}
+	defer os.Remove(markerFile + ".pid")
+
+	pid, err := strconv.Atoi(string(raw))
+	if err != nil {
+		return
+	}
+	if process, err := os.FindProcess(pid); err == nil {
+		_ = process.Kill()
+	}
+}
+
+// Watch polls IMDS for spot interruption notices and rebalance recommendations until the context is done,
+// or until an interruption notice is found. Each notice is reported once.
+func Watch(ctx context.Context, action *githubactions.Action, client *imds.Client, markerFile, hook string, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	reported := map[string]bool{}
+	for {
+		for _, kind := range []string{KindInterruption, KindRebalance} {
+			if reported[kind] {
+				continue
+			}
+			notice, err := checkNotice(ctx, client, kind)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err != nil {
+				action.Warningf("Failed to check spot %s notice: %v", kind, err)
+				continue
+			}
+			if notice == nil {
+				continue
+			}
+			reported[kind] = true
+			handleNotice(ctx, action, notice, markerFile, hook)
+			if kind == KindInterruption {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkNotice returns the notice of the given kind if IMDS has one, or nil otherwise
+func checkNotice(ctx context.Context, client *imds.Client, kind string) (*Notice, error) {
+	path := instanceActionPath
+	if kind == KindRebalance {
+		path = rebalancePath
+	}
+
+	output, err := client.GetMetadata(ctx, &imds.GetMetadataInput{Path: path})
+	if err != nil {
+		var respErr *smithyhttp.ResponseError
+		if errors.As(err, &respErr) && respErr.HTTPStatusCode() == 404 {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer output.Content.Close()
+
+	raw, err := io.ReadAll(output.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	var content struct {
+		Action     string `json:"action"`
+		Time       string `json:"time"`
+		NoticeTime string `json:"noticeTime"`
+	}
+	if err := json.Unmarshal(raw, &content); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	notice := &Notice{Kind: kind, Action: content.Action, Time: content.Time, DetectedAt: time.Now().UTC()}
+	if kind == KindRebalance {
+		notice.Time = content.NoticeTime
+	}
+	return notice, nil
+}
+
+// handleNotice logs a notice, writes the marker file and runs the user hook.
+// The watcher is detached, so the annotation only goes to its log file: the hook is the only way
+// to get the notice out of an instance that is reclaimed before the post step.
+func handleNotice(ctx context.Context, action *githubactions.Action, notice *Notice, markerFile, hook string) {
+	annotate(action, notice)
+
+	if err := writeMarker(markerFile, notice); err != nil {
+		action.Warningf("Failed to write spot marker file: %v", err)
+	}
+
+	if hook == "" {
+		return
+	}
+	hookCtx, cancel := context.WithTimeout(ctx, hookTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(hookCtx, "sh", "-c", hook)
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(hookCtx, "cmd", "/C", hook)
+	}
+	cmd.Env = append(os.Environ(),
+		"RUNS_ON_SPOT_NOTICE_KIND="+notice.Kind,
+		"RUNS_ON_SPOT_NOTICE_TIME="+notice.Time,
+		"RUNS_ON_SPOT_MARKER_FILE="+markerFile,
+	)
+	output, err := cmd.CombinedOutput()
+	action.Infof("Spot hook output: %s", string(output))
+	if err != nil {
+		action.Warningf("Spot hook failed: %v", err)
+	}
+}
+
+// writeMarker writes the notices found so far to the marker file, interruption notices taking precedence
+func writeMarker(markerFile string, notice *Notice) error {
+	if existing, err := ReadMarker(markerFile); err == nil && existing.Kind == KindInterruption {
+		return nil
+	}
+	raw, err := json.MarshalIndent(notice, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(markerFile, raw, 0644)
+}
+
+// ReadMarker reads the notice persisted in the marker file.
+func ReadMarker(markerFile string) (*Notice, error) {
+	raw, err := os.ReadFile(markerFile)
+	if err != nil {
+		return nil, err
+	}
+	notice := &Notice{}
+	if err := json.Unmarshal(raw, notice); err != nil {
+		return nil, fmt.Errorf("failed to parse spot marker file: %w", err)
+	}
+	return notice, nil
+}
+
+// ReportNotice reports a notice found by the background watcher during the job, if any.
+// Annotations written by the detached watcher do not reach the job log, hence this report in the post step.
+func ReportNotice(action *githubactions.Action, markerFile string) {
+	notice, err := ReadMarker(markerFile)
+	if err != nil {
+		return
+	}
+	annotate(action, notice)
+}
+
+// annotate emits an error annotation for interruption notices, and a warning for rebalance recommendations
+func annotate(action *githubactions.Action, notice *Notice) {
+	annotated := action.WithFieldsMap(map[string]string{"title": "Spot " + notice.Kind})
+	if notice.Kind == KindInterruption {
+		annotated.Errorf("Spot interruption notice received at %s: instance will be reclaimed (%s) at %s",
+			notice.DetectedAt.Format(time.RFC3339), notice.Action, notice.Time)
+	} else {
+		annotated.Warningf("Spot rebalance recommendation received at %s (notice time %s): instance is at elevated risk of interruption",
+			notice.DetectedAt.Format(time.RFC3339), notice.Time)
+	}
+}
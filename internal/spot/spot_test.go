@@ -0,0 +1,90 @@
+package spot
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/runs-on/action/internal/testutil"
+	"github.com/sethvargo/go-githubactions"
+)
+
+func TestWatchInterruption(t *testing.T) {
+	client := testutil.NewIMDSServer(t, map[string]string{
+		instanceActionPath: `{"action": "terminate", "time": "2026-10-18T08:22:00Z"}`,
+	})
+	var out bytes.Buffer
+	action := githubactions.New(githubactions.WithWriter(&out))
+	markerFile := filepath.Join(t.TempDir(), "notice.json")
+	hookOutput := filepath.Join(t.TempDir(), "hook")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := Watch(ctx, action, client, markerFile, "echo $RUNS_ON_SPOT_NOTICE_KIND > "+hookOutput, 10*time.Millisecond); err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+
+	if !strings.Contains(out.String(), "::error title=Spot interruption::") || !strings.Contains(out.String(), "2026-10-18T08:22:00Z") {
+		t.Errorf("expected interruption annotation, got:\n%s", out.String())
+	}
+	notice, err := ReadMarker(markerFile)
+	if err != nil {
+		t.Fatalf("ReadMarker() error = %v", err)
+	}
+	if notice.Kind != KindInterruption || notice.Action != "terminate" || notice.Time != "2026-10-18T08:22:00Z" {
+		t.Errorf("unexpected notice: %+v", notice)
+	}
+	// The hook gets the notice in its environment
+	hookRun, err := os.ReadFile(hookOutput)
+	if err != nil {
+		t.Fatalf("failed to read hook output: %v", err)
+	}
+	if !strings.Contains(string(hookRun), KindInterruption) {
+		t.Errorf("hook output = %q, want %q", hookRun, KindInterruption)
+	}
+}
+
+func TestWatchNoNotice(t *testing.T) {
+	client := testutil.NewIMDSServer(t, map[string]string{})
+	var out bytes.Buffer
+	action := githubactions.New(githubactions.WithWriter(&out))
+	markerFile := filepath.Join(t.TempDir(), "notice.json")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := Watch(ctx, action, client, markerFile, "", 10*time.Millisecond); err != context.DeadlineExceeded {
+		t.Fatalf("Watch() error = %v, want deadline exceeded", err)
+	}
+
+	if out.Len() != 0 {
+		t.Errorf("expected no output, got:\n%s", out.String())
+	}
+	if _, err := ReadMarker(markerFile); err == nil {
+		t.Errorf("expected no marker file")
+	}
+}
+
+func TestWatchRebalance(t *testing.T) {
+	client := testutil.NewIMDSServer(t, map[string]string{
+		rebalancePath: `{"noticeTime": "2026-10-18T08:10:00Z"}`,
+	})
+	var out bytes.Buffer
+	action := githubactions.New(githubactions.WithWriter(&out))
+	markerFile := filepath.Join(t.TempDir(), "notice.json")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	Watch(ctx, action, client, markerFile, "", 10*time.Millisecond)
+
+	if strings.Count(out.String(), "::warning title=Spot rebalance::") != 1 {
+		t.Errorf("expected a single rebalance warning, got:\n%s", out.String())
+	}
+	notice, err := ReadMarker(markerFile)
+	if err != nil || notice.Kind != KindRebalance || notice.Time != "2026-10-18T08:10:00Z" {
+		t.Errorf("unexpected notice: %+v (%v)", notice, err)
+	}
+}
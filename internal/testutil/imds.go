@@ -0,0 +1,36 @@
+// Package testutil holds test helpers shared by several packages.
+package testutil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+)
+
+// NewIMDSServer returns a client of a minimal IMDSv2 stand-in serving the given metadata paths,
+// relative to /latest/meta-data/. Requests without the session token are rejected.
+func NewIMDSServer(t *testing.T, metadata map[string]string) *imds.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut && r.URL.Path == "/latest/api/token" {
+			w.Header().Set("X-Aws-Ec2-Metadata-Token-Ttl-Seconds", "21600")
+			w.Write([]byte("token"))
+			return
+		}
+		if r.Header.Get("X-Aws-Ec2-Metadata-Token") != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		content, ok := metadata[strings.TrimPrefix(r.URL.Path, "/latest/meta-data/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(content))
+	}))
+	t.Cleanup(srv.Close)
+	return imds.New(imds.Options{Endpoint: srv.URL})
+}
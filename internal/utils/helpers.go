@@ -4,12 +4,16 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math"
 	"os"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials/ec2rolecreds"
 	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/runs-on/action/internal/httpclient"
 )
 
 func PrettyPrint(v interface{}) string {
@@ -29,10 +33,103 @@ func GetAWSClientFromEC2IMDS(context context.Context) (*aws.Config, error) {
 		o.Client = imds.New(imds.Options{})
 	})
 
-	cfg, err := config.LoadDefaultConfig(context, config.WithRegion(os.Getenv("RUNS_ON_AWS_REGION")), config.WithCredentialsProvider(aws.NewCredentialsCache(provider)))
+	cfg, err := config.LoadDefaultConfig(context,
+		config.WithRegion(os.Getenv("RUNS_ON_AWS_REGION")),
+		config.WithCredentialsProvider(aws.NewCredentialsCache(provider)),
+		// Sent in the User-Agent of AWS requests, which are retried and honor HTTP(S)_PROXY through the SDK
+		config.WithAppID("runs-on-action_"+httpclient.Version),
+	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load AWS config: %w", err)
 	}
 
 	return &cfg, nil
 }
+
+// GetS3ClientFromEC2IMDS returns an S3 client authenticated with the RunsOn instance profile.
+func GetS3ClientFromEC2IMDS(context context.Context, optFns ...func(*s3.Options)) (*s3.Client, error) {
+	cfg, err := GetAWSClientFromEC2IMDS(context)
+	if err != nil {
+		return nil, err
+	}
+
+	return s3.NewFromConfig(*cfg, optFns...), nil
+}
+
+// S3EndpointOptions returns the S3 client options for a custom endpoint, e.g. a VPC endpoint or an S3-compatible server.
+// Without an endpoint, the client uses AWS S3 in the configured region.
+func S3EndpointOptions(endpoint string, pathStyle bool) func(*s3.Options) {
+	return func(o *s3.Options) {
+		if endpoint == "" {
+			return
+		}
+		o.BaseEndpoint = aws.String(endpoint)
+		o.UsePathStyle = pathStyle
+	}
+}
+
+// ParseS3URL splits an s3://bucket/key URL into its bucket and key.
+func ParseS3URL(s3URL string) (string, string, error) {
+	location, ok := strings.CutPrefix(s3URL, "s3://")
+	if !ok {
+		return "", "", fmt.Errorf("invalid S3 URL %q: missing s3:// prefix", s3URL)
+	}
+
+	bucket, key, _ := strings.Cut(location, "/")
+	if bucket == "" || key == "" {
+		return "", "", fmt.Errorf("invalid S3 URL %q: expected s3://bucket/key", s3URL)
+	}
+
+	return bucket, key, nil
+}
+
+// RenderMarkdownTable renders rows as an aligned markdown table.
+// not using a proper markdown library (yet)
+func RenderMarkdownTable(headers []string, rows [][]string) string {
+	// Find max width for each column
+	colWidths := make([]int, len(headers))
+	for i, h := range headers {
+		colWidths[i] = len(h)
+	}
+	for _, row := range rows {
+		for i, cell := range row {
+			if len(cell) > colWidths[i] {
+				colWidths[i] = len(cell)
+			}
+		}
+	}
+
+	// Helper to pad a row
+	padRow := func(row []string) string {
+		out := "|"
+		for i, cell := range row {
+			out += " " + cell + strings.Repeat(" ", colWidths[i]-len(cell)) + " |"
+		}
+		return out
+	}
+
+	// Build separator
+	sep := "|"
+	for _, w := range colWidths {
+		sep += " " + strings.Repeat("-", w) + " |"
+	}
+
+	var b strings.Builder
+	b.WriteString(padRow(headers) + "\n")
+	b.WriteString(sep + "\n")
+	for _, row := range rows {
+		b.WriteString(padRow(row) + "\n")
+	}
+	return b.String()
+}
+
+// FormatBytes renders a number of bytes with a binary unit, e.g. "1.5 MiB".
+func FormatBytes(v float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	exp := 0
+	for math.Abs(v) >= 1024 && exp < len(units)-1 {
+		v /= 1024
+		exp++
+	}
+	return fmt.Sprintf("%.1f %s", v, units[exp])
+}
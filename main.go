@@ -2,14 +2,23 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
 	"github.com/runs-on/action/internal/cache"
 	"github.com/runs-on/action/internal/config"
 	"github.com/runs-on/action/internal/costs"
 	"github.com/runs-on/action/internal/env"
+	"github.com/runs-on/action/internal/httpclient"
 	"github.com/runs-on/action/internal/monitoring"
+	"github.com/runs-on/action/internal/s3cache"
 	"github.com/runs-on/action/internal/sccache"
+	"github.com/runs-on/action/internal/spot"
 	"github.com/sethvargo/go-githubactions"
 )
 
@@ -20,20 +29,42 @@ func handleMainExecution(action *githubactions.Action, ctx context.Context) {
 		action.Fatalf("Failed to load configuration: %v", err)
 	}
 
+	if cfg.Mode != "" {
+		handleModeExecution(action, cfg)
+		return
+	}
+
 	// Execute logic based on configuration
 	if cfg.HasShowEnv() {
 		env.DisplayEnvVars()
 	}
 
-	cache.UpdateZctionsConfig(action, cfg.ActionsResultsURL, cfg.ZctionsResultsURL, cfg.ZctionsCacheURL, cfg.ActionsRuntimeToken)
+	if err := cache.UpdateZctionsConfig(action, httpclient.New(action, cfg.HTTPTimeout), cfg); err != nil {
+		if cfg.MagicCacheRequired {
+			action.Fatalf("Magic cache is required but not working: %v", err)
+		}
+		action.Warningf("Magic cache is not working, caching will fall back to GitHub: %v", err)
+	} else if cfg.MagicCacheRequired && cfg.ZctionsResultsURL == "" {
+		action.Fatalf("Magic cache is required but not enabled for this runner. Add extras=s3-cache to the runs-on job label.")
+	}
+
+	if cfg.HasDirectoryCache() {
+		if err := s3cache.RunRestore(action, cfg); err != nil {
+			action.Warningf("Failed to restore cache: %v", err)
+		}
+	}
 
 	if cfg.HasShowCosts() {
 		action.Infof("show_costs is enabled. You will find cost details in the post-execution step of this action.")
 	}
 
+	if cfg.IsUsingRunsOn() {
+		costs.CheckCostBudgetEarly(action, cfg)
+	}
+
 	// Configure sccache if requested
 	if cfg.HasSccache() {
-		if err := sccache.ConfigureSccache(action, cfg.Sccache); err != nil {
+		if err := sccache.ConfigureSccache(action, cfg.Sccache, cfg.S3Endpoint, cfg.S3PathStyle); err != nil {
 			action.Errorf("Failed to configure sccache: %v", err)
 		}
 	}
@@ -45,6 +76,41 @@ func handleMainExecution(action *githubactions.Action, ctx context.Context) {
 		}
 	}
 
+	// Watch for spot interruptions in the background, for the rest of the job
+	if cfg.HasSpotWatcher() {
+		if err := spot.StartWatcher(action, cfg.SpotMarkerFile); err != nil {
+			action.Warningf("Failed to start spot interruption watcher: %v", err)
+		}
+	}
+
+	action.Infof("Action finished.")
+}
+
+// handleModeExecution runs a single feature as a regular step, as selected by the 'mode' input.
+func handleModeExecution(action *githubactions.Action, cfg *config.Config) {
+	switch cfg.Mode {
+	case config.ModeCosts:
+		if err := costs.ComputeAndDisplayCosts(action, cfg); errors.Is(err, costs.ErrCostBudgetExceeded) {
+			action.Fatalf("%v", err)
+		} else if err != nil {
+			action.Errorf("Failed to compute or display costs: %v", err)
+		}
+	case config.ModeRollup:
+		if err := costs.DisplayRollup(action, cfg); err != nil {
+			action.Errorf("Failed to compute workflow run costs: %v", err)
+		}
+	case config.ModeCacheAdmin:
+		if err := s3cache.RunAdmin(action, cfg); err != nil {
+			action.Fatalf("Failed to administer cache entries: %v", err)
+		}
+	case config.ModeCacheUsage:
+		if err := s3cache.RunUsage(action, cfg); err != nil {
+			action.Errorf("Failed to report cache usage: %v", err)
+		}
+	default:
+		action.Fatalf("Unsupported mode: %s", cfg.Mode)
+	}
+
 	action.Infof("Action finished.")
 }
 
@@ -57,31 +123,83 @@ func handlePostExecution(action *githubactions.Action, ctx context.Context) {
 		return
 	}
 
+	if cfg.Mode != "" {
+		action.Infof("Nothing to do in post-execution phase for mode '%s'.", cfg.Mode)
+		return
+	}
+
 	if cfg.HasShowEnv() {
 		env.DisplayEnvVars()
 	}
 
+	if cfg.HasSpotWatcher() {
+		spot.StopWatcher(cfg.SpotMarkerFile)
+		spot.ReportNotice(action, cfg.SpotMarkerFile)
+	}
+
+	if cfg.HasDirectoryCache() {
+		if err := s3cache.RunSave(action, cfg); err != nil {
+			action.Warningf("Failed to save cache: %v", err)
+		}
+	}
+
 	err = costs.ComputeAndDisplayCosts(action, cfg)
-	if err != nil {
+	budgetExceeded := errors.Is(err, costs.ErrCostBudgetExceeded)
+	if err != nil && !budgetExceeded {
 		action.Warningf("Failed to compute or display costs: %v", err)
 	}
 
+	if cfg.HasMagicCache() {
+		if err := cache.DisplayStats(action, httpclient.New(action, cfg.HTTPTimeout), cfg.ActionsResultsURL); err != nil {
+			action.Warningf("Failed to display magic cache statistics: %v", err)
+		}
+	}
+
+	if cfg.HasCacheUsage() {
+		if err := s3cache.RunUsage(action, cfg); err != nil {
+			action.Warningf("Failed to report cache usage: %v", err)
+		}
+	}
+
 	// Display metrics summary
 	if cfg.HasMetrics() {
-		monitoring.GenerateMetricsSummary(action, cfg.Metrics, "chart", cfg.NetworkInterface, cfg.DiskDevice)
+		monitoring.GenerateMetricsSummary(action, cfg.Metrics, "chart", cfg.NetworkInterface, cfg.DiskDevice, cfg.MetricsThresholds, cfg.MetricsCPUStacked)
+	}
+
+	// Fail once everything else has been reported
+	if budgetExceeded {
+		action.Fatalf("%v", err)
 	}
 
 	action.Infof("Post-execution phase finished.")
 }
 
+// handleSpotWatcherExecution runs the spot interruption watcher, detached from the main step.
+func handleSpotWatcherExecution(action *githubactions.Action, ctx context.Context) {
+	cfg, err := config.NewConfigFromInputs(action)
+	if err != nil {
+		action.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := spot.Watch(ctx, action, imds.New(imds.Options{}), cfg.SpotMarkerFile, cfg.SpotHook, 5*time.Second); err != nil && ctx.Err() == nil {
+		action.Errorf("Spot interruption watcher failed: %v", err)
+	}
+}
+
 func main() {
 	ctx := context.Background()
 	postFlag := flag.Bool("post", false, "Indicates the post-execution phase")
+	spotWatcherFlag := flag.Bool("spot-watcher", false, "Runs the spot interruption watcher")
 	flag.Parse()
 
 	action := githubactions.New()
 
-	if *postFlag {
+	if *spotWatcherFlag {
+		handleSpotWatcherExecution(action, ctx)
+	} else if *postFlag {
 		handlePostExecution(action, ctx)
 	} else {
 		handleMainExecution(action, ctx)